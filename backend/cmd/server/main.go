@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+
 	"github.com/traP-jp/1m25_10/backend/cmd/server/server"
 	"github.com/traP-jp/1m25_10/backend/pkg/config"
 	"github.com/traP-jp/1m25_10/backend/pkg/database"
@@ -27,8 +29,12 @@ func main() {
 		}
 	}()
 
-	s := server.Inject(db)
+	s, err := server.Inject(db)
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
 	s.SetupRoot(e)
+	s.StartBackground(context.Background())
 
 	e.Logger.Fatal(e.Start(config.AppAddr()))
 }
@@ -1,44 +1,124 @@
 package server
 
 import (
+	"context"
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/traP-jp/1m25_10/backend/internal/feed"
 	"github.com/traP-jp/1m25_10/backend/internal/handler"
+	"github.com/traP-jp/1m25_10/backend/internal/indexer"
 	"github.com/traP-jp/1m25_10/backend/internal/repository"
+	"github.com/traP-jp/1m25_10/backend/internal/session"
+	"github.com/traP-jp/1m25_10/backend/pkg/cache"
+	"github.com/traP-jp/1m25_10/backend/pkg/config"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
 type Server struct {
 	handler *handler.Handler
+	// traQのメッセージをクロールして画像の索引を作る（サービス用のトークンが無ければ nil）
+	indexer *indexer.Indexer
 }
 
-func Inject(db *sqlx.DB) *Server {
+func Inject(db *sqlx.DB) (*Server, error) {
 	repo := repository.New(db)
 
-	// Create an HTTP client with a reasonable timeout for external calls
+	// Create an HTTP client with a reasonable timeout for external calls.
+	// All traQ traffic shares one transport that retries and breaks the circuit while traQ is down.
+	transportCfg := traq.DefaultTransportConfig()
+	transportCfg.MaxRetries = config.TraqRetryMax()
+	transportCfg.BaseDelay = config.TraqRetryBaseDelay()
+	transportCfg.FailureThreshold = config.TraqCircuitFailureThreshold()
+	transportCfg.OpenDuration = config.TraqCircuitOpenDuration()
 	client := &http.Client{
-		Timeout: 15 * time.Second,
+		Timeout:   15 * time.Second,
+		Transport: traq.NewTransport(http.DefaultTransport, transportCfg),
 	}
 
-	h := handler.New(repo, client)
+	traqClient, err := traq.New(config.TraqBaseURL(), client,
+		traq.WithMaxConcurrentRequests(config.TraqMaxConcurrentRequests()))
+	if err != nil {
+		return nil, err
+	}
+
+	var fileCache *cache.Disk
+	if dir := config.FileCacheDir(); dir != "" {
+		fileCache, err = cache.NewDisk(dir, config.FileCacheMaxBytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// ライブフィードで再接続時に送り直せるよう、直近のイベントを保持する
+	hub := feed.NewHub(1000)
+
+	sessionKey, err := sessionEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := session.NewStore(repo, sessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	h := handler.New(repo, traqClient, fileCache, hub, sessions)
+
+	var ix *indexer.Indexer
+	if token := config.TraqServiceToken(); token != "" {
+		ix = indexer.New(repo, traqClient, hub, indexer.Config{
+			Token:         token,
+			Interval:      config.IndexerInterval(),
+			RecrawlWindow: config.IndexerRecrawlWindow(),
+			PollInterval:  config.IndexerPollInterval(),
+		})
+	}
 
 	return &Server{
 		handler: h,
+		indexer: ix,
+	}, nil
+}
+
+// sessionEncryptionKey はセッションのトークンを暗号化する鍵を返す。設定が無ければ生成する（再起動するとログインし直しになる）
+func sessionEncryptionKey() ([]byte, error) {
+	if v := config.SessionEncryptionKey(); v != "" {
+		return session.ParseKey(v)
+	}
+	log.Println("warn: SESSION_ENCRYPTION_KEY is not set; sessions will be invalidated on restart")
+	return session.GenerateKey()
+}
+
+// StartBackground はバックグラウンドの処理（画像の索引のクロール）を開始する。ctx がキャンセルされると終了する
+func (d *Server) StartBackground(ctx context.Context) {
+	if d.indexer != nil {
+		go d.indexer.Run(ctx)
 	}
 }
 
 // ルートレベルのセットアップ
 func (d *Server) SetupRoot(e *echo.Echo) {
+	// すべてのエラーを共通の形式で返す。request_id で問い合わせとログを突き合わせられるようにする
+	e.HTTPErrorHandler = handler.HTTPErrorHandler
+	e.Use(middleware.RequestID())
+
 	// top-level /api group
-	api := e.Group("/api")
+	// Cookie のセッションから traQ のアクセストークンを読み込む
+	api := e.Group("/api", d.handler.SessionProvider)
 
 	// /api/auth
 	authGroup := api.Group("/auth")
 	d.handler.SetupAuthRoutes(authGroup)
 
+	// /api/bot
+	botGroup := api.Group("/bot")
+	d.handler.SetupBotRoutes(botGroup)
+
 	// /api/v1
 	v1Group := api.Group("/v1")
 	d.handler.SetupAppRoutes(v1Group)
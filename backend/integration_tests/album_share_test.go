@@ -0,0 +1,94 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// 投稿したメッセージは他のテストの検索結果に影響するため、並行に実行せず最後に削除する
+func TestAlbumShare(t *testing.T) {
+	album := createAlbum(t, fmt.Sprintf(`{"title":"夕焼け","description":"","images":[%q,%q,%q]}`, sunsetFileID, seaFileID, sunsetCopyFileID))
+	t.Cleanup(func() { doRequest(t, http.MethodDelete, "/api/v1/albums/"+album.ID, "", withUser("alice")) })
+	sharePath := "/api/v1/albums/" + album.ID + "/share/traq"
+
+	share := func(t *testing.T, body string) string {
+		t.Helper()
+		rec := doRequest(t, http.MethodPost, sharePath, body, withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusCreated, rec.Body.String())
+		var res struct {
+			MessageID string `json:"message_id"`
+			ChannelID string `json:"channel_id"`
+			URL       string `json:"url"`
+		}
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		t.Cleanup(func() { fakeTraq.DeleteMessage(res.MessageID) })
+		assert.Equal(t, res.ChannelID, generalChannelID)
+		assert.Equal(t, res.URL, fakeTraq.URL+"/messages/"+res.MessageID)
+
+		m, ok := fakeTraq.Message(res.MessageID)
+		assert.Assert(t, ok)
+		assert.Equal(t, m.UserID, aliceID)
+		return m.Content
+	}
+
+	t.Run("post album link and images", func(t *testing.T) {
+		content := share(t, fmt.Sprintf(`{"channel_id":%q,"comment":"見てください"}`, generalChannelID))
+		lines := strings.Split(content, "\n")
+		assert.Equal(t, len(lines), 6, content)
+		assert.Assert(t, strings.Contains(lines[0], "夕焼け"), content)
+		assert.Equal(t, lines[1], "見てください")
+		assert.Assert(t, strings.HasSuffix(lines[2], "/albums/"+album.ID), content)
+		assert.DeepEqual(t, lines[3:], []string{
+			fakeTraq.FileURL(sunsetFileID),
+			fakeTraq.FileURL(seaFileID),
+			fakeTraq.FileURL(sunsetCopyFileID),
+		})
+	})
+
+	t.Run("limit embedded images", func(t *testing.T) {
+		content := share(t, fmt.Sprintf(`{"channel_id":%q,"max_images":1}`, generalChannelID))
+		lines := strings.Split(content, "\n")
+		assert.Equal(t, len(lines), 3, content)
+		assert.Equal(t, lines[2], fakeTraq.FileURL(sunsetFileID))
+	})
+
+	t.Run("read only grant", func(t *testing.T) {
+		const readOnlyToken = "alice-read-only-token"
+		fakeTraq.AddToken(readOnlyToken, aliceID, "read")
+
+		rec := doRequest(t, http.MethodPost, sharePath, fmt.Sprintf(`{"channel_id":%q}`, generalChannelID), withToken(readOnlyToken))
+		assert.Equal(t, rec.Code, http.StatusForbidden, rec.Body.String())
+		res := decodeError(t, rec)
+		assert.Equal(t, res.Error, "write_scope_required")
+		var details struct {
+			ReauthorizeURL string `json:"reauthorize_url"`
+		}
+		assert.NilError(t, json.Unmarshal(res.Details, &details))
+		assert.Equal(t, details.ReauthorizeURL, "/api/auth/request?callback=%2Falbums%2F"+album.ID)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		body := fmt.Sprintf(`{"channel_id":%q}`, generalChannelID)
+		rec := doRequest(t, http.MethodPost, sharePath, body)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+		rec = doRequest(t, http.MethodPost, sharePath, `{"channel_id":"general"}`, withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+		rec = doRequest(t, http.MethodPost, sharePath, fmt.Sprintf(`{"channel_id":%q,"max_images":11}`, generalChannelID), withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+		rec = doRequest(t, http.MethodPost, "/api/v1/albums/"+bobID+"/share/traq", body, withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+
+		// 存在しないチャンネル
+		rec = doRequest(t, http.MethodPost, sharePath, fmt.Sprintf(`{"channel_id":%q}`, bobID), withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+		assert.Equal(t, decodeError(t, rec).Error, "traq_not_found")
+	})
+}
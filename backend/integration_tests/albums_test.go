@@ -0,0 +1,133 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+type albumResponse struct {
+	ID           string                     `json:"id"`
+	Title        string                     `json:"title"`
+	Description  string                     `json:"description"`
+	Creator      string                     `json:"creator"`
+	Images       []string                   `json:"images"`
+	Placeholders map[string]json.RawMessage `json:"placeholders"`
+}
+
+func createAlbum(t *testing.T, body string) albumResponse {
+	t.Helper()
+	rec := doRequest(t, http.MethodPost, "/api/v1/albums", body, withUser("alice"), withToken(aliceToken))
+	assert.Equal(t, rec.Code, http.StatusCreated, rec.Body.String())
+
+	var a albumResponse
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &a))
+	return a
+}
+
+func TestAlbums(t *testing.T) {
+	t.Run("crud", func(t *testing.T) {
+		t.Parallel()
+		created := createAlbum(t, fmt.Sprintf(`{"title":"旅行","description":"夏","images":[%q,%q]}`, sunsetFileID, seaFileID))
+		assert.Equal(t, created.Title, "旅行")
+		assert.Equal(t, created.Creator, "alice")
+		assert.DeepEqual(t, created.Images, []string{sunsetFileID, seaFileID})
+
+		rec := doRequest(t, http.MethodGet, "/api/v1/albums/"+created.ID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		var got albumResponse
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, got.Description, "夏")
+		assert.DeepEqual(t, got.Images, []string{sunsetFileID, seaFileID})
+
+		// 作成者以外は更新できない
+		rec = doRequest(t, http.MethodPatch, "/api/v1/albums/"+created.ID, `{"title":"x"}`, withUser("bob"))
+		assert.Equal(t, rec.Code, http.StatusForbidden)
+
+		rec = doRequest(t, http.MethodPatch, "/api/v1/albums/"+created.ID,
+			fmt.Sprintf(`{"title":"旅行2","images":[%q]}`, seaFileID), withUser("alice"))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		var updated albumResponse
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+		assert.Equal(t, updated.Title, "旅行2")
+		assert.DeepEqual(t, updated.Images, []string{seaFileID})
+
+		// 画像のみの更新
+		rec = doRequest(t, http.MethodPatch, "/api/v1/albums/"+created.ID,
+			fmt.Sprintf(`{"images":[%q,%q]}`, seaFileID, sunsetFileID), withUser("alice"))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+		assert.Equal(t, updated.Title, "旅行2")
+		assert.Equal(t, len(updated.Images), 2)
+
+		// 値が変わらない更新も成功する（updated_at は秒単位なので同じ秒の更新では行が変わらない）
+		for range 2 {
+			rec = doRequest(t, http.MethodPatch, "/api/v1/albums/"+created.ID, `{"title":"旅行2"}`, withUser("alice"))
+			assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		}
+
+		rec = doRequest(t, http.MethodDelete, "/api/v1/albums/"+created.ID, "", withUser("alice"))
+		assert.Equal(t, rec.Code, http.StatusNoContent)
+
+		rec = doRequest(t, http.MethodGet, "/api/v1/albums/"+created.ID, "")
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+	})
+
+	t.Run("create without user", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodPost, "/api/v1/albums", `{"title":"x"}`)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		t.Parallel()
+		created := createAlbum(t, fmt.Sprintf(`{"title":"重複","images":[%q,%q,%q]}`, sunsetFileID, seaFileID, sunsetCopyFileID))
+
+		rec := doRequest(t, http.MethodGet, "/api/v1/albums/"+created.ID+"/duplicates", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		var res struct {
+			Groups []struct {
+				Images []string `json:"images"`
+			} `json:"groups"`
+		}
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, len(res.Groups), 1)
+		assert.DeepEqual(t, res.Groups[0].Images, []string{sunsetFileID, sunsetCopyFileID})
+	})
+
+	t.Run("dedupe on create", func(t *testing.T) {
+		t.Parallel()
+		created := createAlbum(t, fmt.Sprintf(`{"title":"重複なし","dedupe":true,"images":[%q,%q,%q]}`, sunsetFileID, seaFileID, sunsetCopyFileID))
+		assert.DeepEqual(t, created.Images, []string{sunsetFileID, seaFileID})
+	})
+}
+
+func TestAlbumSortByTakenAt(t *testing.T) {
+	t.Parallel()
+	// sea は 2025-01-03、sunset は 2025-01-01 に投稿された（どちらもEXIFは無い）
+	created := createAlbum(t, fmt.Sprintf(`{"title":"撮影順","images":[%q,%q]}`, seaFileID, sunsetFileID))
+	path := "/api/v1/albums/" + created.ID + "?sort=taken_at"
+
+	// メタデータの抽出はバックグラウンドで行うので、抽出前もすぐに応答する
+	rec := doRequest(t, http.MethodGet, path, "", withToken(aliceToken))
+	assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+	var got albumResponse
+	for range 50 {
+		rec = doRequest(t, http.MethodGet, path, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		if got.Images[0] == sunsetFileID {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.DeepEqual(t, got.Images, []string{sunsetFileID, seaFileID})
+
+	rec = doRequest(t, http.MethodGet, "/api/v1/albums/"+created.ID+"?sort=name", "")
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+}
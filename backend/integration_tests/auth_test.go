@@ -0,0 +1,87 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAuth(t *testing.T) {
+	t.Run("oauth flow", func(t *testing.T) {
+		t.Parallel()
+
+		// アプリ → traQの認可エンドポイント
+		rec := doRequest(t, http.MethodGet, "/api/auth/request?callback=/albums", "")
+		assert.Equal(t, rec.Code, http.StatusFound)
+		authURL := rec.Header().Get("Location")
+		assert.Assert(t, strings.HasPrefix(authURL, fakeTraq.URL+"/api/v3/oauth2/authorize?"), authURL)
+		u, err := url.Parse(authURL)
+		assert.NilError(t, err)
+		// アルバムの共有（メッセージの投稿）のために write スコープも要求する
+		assert.Equal(t, u.Query().Get("scope"), "read write")
+		tempCookies := rec.Result().Cookies()
+
+		// traQ → アプリのコールバック
+		client := &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		resp, err := client.Get(authURL)
+		assert.NilError(t, err)
+		assert.NilError(t, resp.Body.Close())
+		assert.Equal(t, resp.StatusCode, http.StatusFound)
+		callback, err := url.Parse(resp.Header.Get("Location"))
+		assert.NilError(t, err)
+		assert.Equal(t, callback.Path, "/api/auth/callback")
+
+		rec = doRequest(t, http.MethodGet, callback.RequestURI(), "", withCookies(tempCookies))
+		assert.Equal(t, rec.Code, http.StatusFound)
+		assert.Equal(t, rec.Header().Get("Location"), "/albums")
+
+		// Cookie にはアクセストークンではなくセッションを置く
+		var authCookie *http.Cookie
+		for _, ck := range rec.Result().Cookies() {
+			assert.Assert(t, ck.Name != "traq-auth-token" || ck.Value == "", "access token must not be stored in cookies")
+			if ck.Name == "traq-auth-session" {
+				authCookie = ck
+			}
+		}
+		assert.Assert(t, authCookie != nil)
+		assert.Assert(t, authCookie.HttpOnly)
+
+		// セッションで traQ のユーザー情報を取得できる
+		rec = doRequest(t, http.MethodGet, "/api/auth/me", "", withCookies([]*http.Cookie{authCookie}))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		var me struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &me))
+		assert.Equal(t, me.ID, aliceID)
+		assert.Equal(t, me.Name, "alice")
+	})
+
+	t.Run("state mismatch", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/auth/request", "")
+		assert.Equal(t, rec.Code, http.StatusFound)
+
+		rec = doRequest(t, http.MethodGet, "/api/auth/callback?code=x&state=wrong", "", withCookies(rec.Result().Cookies()))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("me without token", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/auth/me", "")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("me with invalid token", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/auth/me", "", withToken("invalid"))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,108 @@
+package integration_tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestBotEvents(t *testing.T) {
+	t.Parallel()
+	app := httptest.NewServer(e)
+	defer app.Close()
+	ctx := context.Background()
+	sender := fakeTraq.NewBotEventSender(app.URL+"/api/bot/events", botVerificationToken)
+
+	// gps/times/alice の画像を索引から探す
+	search := func(t *testing.T, query string) []string {
+		t.Helper()
+		rec := doRequest(t, http.MethodGet, "/api/v1/images?source=local&in="+aliceTimesChannelID+query, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		var res imageSearchResponse
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res.Hits
+	}
+
+	status, err := sender.Ping(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, status, http.StatusNoContent)
+
+	const messageID = "7d6c5b4a-1a2b-4c3d-8e9f-0a1b2c3d4e10"
+	now := time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+	status, err = sender.MessageCreated(ctx, traq.Message{
+		ID:        messageID,
+		UserID:    aliceID,
+		ChannelID: aliceTimesChannelID,
+		Content:   "海\n" + fakeTraq.FileURL(seaFileID),
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, status, http.StatusNoContent)
+	assert.DeepEqual(t, search(t, ""), []string{seaFileID})
+
+	status, err = sender.BotMessageStampsUpdated(ctx, messageID, []traq.MessageStamp{
+		{UserID: bobID, StampID: cameraStampID, Count: 2, CreatedAt: now, UpdatedAt: now},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, status, http.StatusNoContent)
+	assert.DeepEqual(t, search(t, "&stamp=camera&minStampCount=2"), []string{seaFileID})
+
+	// スタンプの合計数が変わらない更新も反映される
+	status, err = sender.BotMessageStampsUpdated(ctx, messageID, []traq.MessageStamp{
+		{UserID: bobID, StampID: goodStampID, Count: 2, CreatedAt: now, UpdatedAt: now},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, status, http.StatusNoContent)
+	assert.DeepEqual(t, search(t, "&stamp=good"), []string{seaFileID})
+	assert.DeepEqual(t, search(t, "&stamp=camera"), []string{})
+
+	status, err = sender.MessageDeleted(ctx, messageID, aliceTimesChannelID)
+	assert.NilError(t, err)
+	assert.Equal(t, status, http.StatusNoContent)
+	assert.DeepEqual(t, search(t, ""), []string{})
+
+	// 画像を含まないメッセージは索引しない
+	status, err = sender.MessageCreated(ctx, traq.Message{
+		ID:        "7d6c5b4a-1a2b-4c3d-8e9f-0a1b2c3d4e11",
+		UserID:    aliceID,
+		ChannelID: aliceTimesChannelID,
+		Content:   "画像なし",
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, status, http.StatusNoContent)
+	assert.DeepEqual(t, search(t, ""), []string{})
+
+	// プライベートチャンネルや DM のメッセージは索引しない
+	const privateChannelID = "5b0e7c1e-3f5a-4d8e-8d1b-2f6a0c7b2cff"
+	status, err = sender.MessageCreated(ctx, traq.Message{
+		ID:        "7d6c5b4a-1a2b-4c3d-8e9f-0a1b2c3d4e12",
+		UserID:    aliceID,
+		ChannelID: privateChannelID,
+		Content:   "秘密\n" + fakeTraq.FileURL(seaFileID),
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, status, http.StatusNoContent)
+	rec := doRequest(t, http.MethodGet, "/api/v1/images?source=local&in="+privateChannelID, "", withToken(aliceToken))
+	assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+	var res imageSearchResponse
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.DeepEqual(t, res.Hits, []string{})
+
+	t.Run("invalid verification token", func(t *testing.T) {
+		status, err := fakeTraq.NewBotEventSender(app.URL+"/api/bot/events", "wrong").Ping(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, status, http.StatusForbidden)
+	})
+}
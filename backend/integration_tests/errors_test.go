@@ -0,0 +1,65 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"gotest.tools/v3/assert"
+)
+
+func TestErrorResponse(t *testing.T) {
+	t.Run("handler error", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/albums/00000000-0000-0000-0000-000000000000", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+
+		res := decodeError(t, rec)
+		assert.Equal(t, res.Error, "not_found")
+		assert.Assert(t, res.Message != "")
+		assert.Assert(t, res.RequestID != "")
+		assert.Equal(t, res.RequestID, rec.Header().Get(echo.HeaderXRequestID))
+	})
+
+	t.Run("request ID from the client is echoed", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/stamps", "", withHeader(echo.HeaderXRequestID, "req-123"))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+		res := decodeError(t, rec)
+		assert.Equal(t, res.Error, "unauthorized")
+		assert.Equal(t, res.Message, "authentication required")
+		assert.Equal(t, res.RequestID, "req-123")
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/nowhere", "")
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+		assert.Equal(t, decodeError(t, rec).Error, "not_found")
+	})
+
+	t.Run("traQ error is mapped to a stable code", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/messages?limit=101", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+		res := decodeError(t, rec)
+		assert.Equal(t, res.Error, "traq_bad_request")
+		var details struct {
+			Status  int    `json:"status"`
+			Message string `json:"message"`
+		}
+		assert.NilError(t, json.Unmarshal(res.Details, &details))
+		assert.Equal(t, details.Status, http.StatusBadRequest)
+		assert.Assert(t, details.Message != "")
+	})
+
+	t.Run("traQ file error is not relayed as-is", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/files/00000000-0000-0000-0000-000000000000", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+		assert.Equal(t, decodeError(t, rec).Error, "traq_not_found")
+	})
+}
@@ -0,0 +1,116 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+type histogramResponse struct {
+	Bucket   string `json:"bucket"`
+	Timezone string `json:"timezone"`
+	Total    int    `json:"total"`
+	Buckets  []struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+		Count int       `json:"count"`
+	} `json:"buckets"`
+}
+
+func getHistogram(t *testing.T, query string) histogramResponse {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, "/api/v1/images/histogram"+query, "", withToken(aliceToken))
+	assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+	var res histogramResponse
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return res
+}
+
+func histogramCounts(res histogramResponse) []int {
+	counts := make([]int, 0, len(res.Buckets))
+	for _, b := range res.Buckets {
+		counts = append(counts, b.Count)
+	}
+	return counts
+}
+
+// traQ への検索の回数を確かめるため、並行に実行しない
+func TestImageHistogram(t *testing.T) {
+	t.Run("daily in Asia/Tokyo by default", func(t *testing.T) {
+		res := getHistogram(t, "?after=2024-12-30&before=2025-01-05")
+		assert.Equal(t, res.Bucket, "day")
+		assert.Equal(t, res.Timezone, "Asia/Tokyo")
+		assert.DeepEqual(t, histogramCounts(res), []int{0, 0, 1, 1, 1, 0})
+		assert.Equal(t, res.Total, 3)
+
+		jst := time.FixedZone("", 9*60*60)
+		assert.Assert(t, res.Buckets[0].Start.Equal(time.Date(2024, 12, 30, 0, 0, 0, 0, jst)))
+		assert.Assert(t, res.Buckets[5].End.Equal(time.Date(2025, 1, 5, 0, 0, 0, 0, jst)))
+		_, offset := res.Buckets[0].Start.Zone()
+		assert.Equal(t, offset, 9*60*60)
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		before := fakeTraq.Requests(traqMessagesPath)
+		res := getHistogram(t, "?after=2024-12-30&before=2025-01-05")
+		assert.Equal(t, res.Total, 3)
+		assert.Equal(t, fakeTraq.Requests(traqMessagesPath), before)
+
+		// 条件が違えば改めて数える
+		getHistogram(t, "?after=2024-12-30&before=2025-01-06")
+		assert.Equal(t, fakeTraq.Requests(traqMessagesPath), before+1)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		res := getHistogram(t, "?after=2024-12-30&before=2025-01-05&in="+generalChannelID)
+		assert.DeepEqual(t, histogramCounts(res), []int{0, 0, 1, 0, 1, 0})
+
+		res = getHistogram(t, "?after=2024-12-30&before=2025-01-05&channel=general&from="+aliceID)
+		assert.DeepEqual(t, histogramCounts(res), []int{0, 0, 1, 0, 0, 0})
+	})
+
+	t.Run("timezone", func(t *testing.T) {
+		// ホノルル (UTC-10) では 2025-01-01T09:00Z の投稿は前日になる
+		res := getHistogram(t, "?after=2024-12-30&before=2025-01-05&tz=Pacific/Honolulu")
+		assert.Equal(t, res.Timezone, "Pacific/Honolulu")
+		assert.DeepEqual(t, histogramCounts(res), []int{0, 1, 1, 1, 0, 0})
+	})
+
+	t.Run("weekly", func(t *testing.T) {
+		// 2025-01-01 は水曜日。週は月曜始まり
+		res := getHistogram(t, "?bucket=week&after=2025-01-01&before=2025-01-08")
+		assert.DeepEqual(t, histogramCounts(res), []int{3, 0})
+		assert.Equal(t, res.Buckets[0].Start.Format(time.DateOnly), "2024-12-30")
+		assert.Equal(t, res.Buckets[1].End.Format(time.DateOnly), "2025-01-13")
+	})
+
+	t.Run("defaults to recent buckets", func(t *testing.T) {
+		res := getHistogram(t, "")
+		assert.Equal(t, len(res.Buckets), 30)
+		assert.Assert(t, res.Buckets[29].End.After(time.Now()))
+
+		res = getHistogram(t, "?bucket=week")
+		assert.Equal(t, len(res.Buckets), 12)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		rec := doRequest(t, http.MethodGet, "/api/v1/images/histogram", "")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+		for _, q := range []string{
+			"?bucket=month",
+			"?tz=Mars/Olympus",
+			"?in=general",
+			"?from=alice",
+			"?after=yesterday",
+			"?after=2025-01-05&before=2025-01-01",
+			"?after=2020-01-01&before=2025-01-01",
+		} {
+			rec := doRequest(t, http.MethodGet, "/api/v1/images/histogram"+q, "", withToken(aliceToken))
+			assert.Equal(t, rec.Code, http.StatusBadRequest, q)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"gotest.tools/v3/assert"
+)
+
+// スタンプの付け外しは他のテストの検索結果に影響するため、並行に実行せず最後に元に戻す
+func TestImageStamps(t *testing.T) {
+	stampPath := "/api/v1/images/" + sunsetFileID + "/stamps/" + cameraStampID
+	t.Cleanup(func() { doRequest(t, http.MethodDelete, stampPath, "", withToken(aliceToken)) })
+
+	// 画像を最初に投稿したメッセージについた alice のスタンプ
+	aliceStamp := func(t *testing.T) (traq.MessageStamp, bool) {
+		t.Helper()
+		m, ok := fakeTraq.Message(sunsetMessageID)
+		assert.Assert(t, ok)
+		for _, s := range m.Stamps {
+			if s.UserID == aliceID && s.StampID == cameraStampID {
+				return s, true
+			}
+		}
+		return traq.MessageStamp{}, false
+	}
+
+	t.Run("add and remove", func(t *testing.T) {
+		rec := doRequest(t, http.MethodPost, stampPath, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNoContent, rec.Body.String())
+		s, ok := aliceStamp(t)
+		assert.Assert(t, ok)
+		assert.Equal(t, s.Count, 1)
+
+		rec = doRequest(t, http.MethodPost, stampPath, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNoContent, rec.Body.String())
+		s, _ = aliceStamp(t)
+		assert.Equal(t, s.Count, 2)
+
+		// 付けたスタンプは検索結果にも反映される
+		rec = doRequest(t, http.MethodGet, "/api/v1/images/"+sunsetFileID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		var m traq.Message
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+		assert.Equal(t, m.ID, sunsetMessageID)
+		assert.Equal(t, len(m.Stamps), 2)
+
+		rec = doRequest(t, http.MethodDelete, stampPath, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNoContent, rec.Body.String())
+		_, ok = aliceStamp(t)
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("read only grant", func(t *testing.T) {
+		const readOnlyToken = "alice-read-only-stamp-token"
+		fakeTraq.AddToken(readOnlyToken, aliceID, "read")
+
+		rec := doRequest(t, http.MethodPost, stampPath, "", withToken(readOnlyToken))
+		assert.Equal(t, rec.Code, http.StatusForbidden, rec.Body.String())
+		assert.Equal(t, decodeError(t, rec).Error, "write_scope_required")
+		_, ok := aliceStamp(t)
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		rec := doRequest(t, http.MethodPost, stampPath, "")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+		rec = doRequest(t, http.MethodPost, "/api/v1/images/"+sunsetFileID+"/stamps/camera", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+		// 画像を含むメッセージが無い
+		rec = doRequest(t, http.MethodPost, "/api/v1/images/"+bobID+"/stamps/"+cameraStampID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+
+		// 存在しないスタンプ
+		rec = doRequest(t, http.MethodPost, "/api/v1/images/"+sunsetFileID+"/stamps/"+bobID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+		assert.Equal(t, decodeError(t, rec).Error, "traq_bad_request")
+	})
+}
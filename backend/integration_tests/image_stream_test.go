@@ -0,0 +1,232 @@
+package integration_tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/internal/feed"
+	"github.com/traP-jp/1m25_10/backend/internal/indexer"
+	"github.com/traP-jp/1m25_10/backend/internal/repository"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq/traqtest"
+
+	"gotest.tools/v3/assert"
+)
+
+// streamEvent は Server-Sent Events のイベント1件
+type streamEvent struct {
+	ID    uint64
+	Event string
+	Hit   struct {
+		ID         string `json:"id"`
+		MessageID  string `json:"messageId"`
+		ChannelID  string `json:"channelId"`
+		StampCount int    `json:"stampCount"`
+	}
+}
+
+// openStream は GET /api/v1/images/stream に接続し、受信したイベントを返すチャネルを返す
+func openStream(t *testing.T, baseURL, query string, header http.Header) <-chan streamEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/api/v1/images/stream"+query, nil)
+	assert.NilError(t, err)
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.AddCookie(sessionCookie(aliceToken))
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, resp.Header.Get("Content-Type"), "text/event-stream")
+	t.Cleanup(func() {
+		cancel()
+		_ = resp.Body.Close()
+	})
+
+	events := make(chan streamEvent, 16)
+	go func() {
+		defer close(events)
+		var ev streamEvent
+		var data string
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == "":
+				if ev.Event != "" {
+					_ = json.Unmarshal([]byte(data), &ev.Hit)
+					events <- ev
+				}
+				ev, data = streamEvent{}, ""
+			case strings.HasPrefix(line, "id: "):
+				ev.ID, _ = strconv.ParseUint(strings.TrimPrefix(line, "id: "), 10, 64)
+			case strings.HasPrefix(line, "event: "):
+				ev.Event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return events
+}
+
+func nextStreamEvent(t *testing.T, events <-chan streamEvent) streamEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		assert.Assert(t, ok, "stream closed")
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a stream event")
+		return streamEvent{}
+	}
+}
+
+func assertNoStreamEvent(t *testing.T, events <-chan streamEvent) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected stream event: %+v", ev)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestImageStream(t *testing.T) {
+	t.Parallel()
+	app := httptest.NewServer(e)
+	// 配信中の接続が閉じられてから終了するよう、接続より先に登録する
+	t.Cleanup(app.Close)
+	ctx := context.Background()
+	sender := fakeTraq.NewBotEventSender(app.URL+"/api/bot/events", botVerificationToken)
+
+	post := func(t *testing.T, id, channelID string, fileIDs ...string) {
+		t.Helper()
+		content := "ライブ"
+		for _, f := range fileIDs {
+			content += "\n" + fakeTraq.FileURL(f)
+		}
+		now := time.Now()
+		status, err := sender.MessageCreated(ctx, traq.Message{
+			ID:        id,
+			UserID:    aliceID,
+			ChannelID: channelID,
+			Content:   content,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, status, http.StatusNoContent)
+	}
+
+	const (
+		generalMessageID = "7d6c5b4a-1a2b-4c3d-8e9f-0a1b2c3d4e20"
+		gpsMessageID     = "7d6c5b4a-1a2b-4c3d-8e9f-0a1b2c3d4e21"
+		stampedMessageID = "7d6c5b4a-1a2b-4c3d-8e9f-0a1b2c3d4e22"
+	)
+
+	// チャンネルで絞り込む
+	gps := openStream(t, app.URL, "?in="+gpsChannelID, nil)
+	post(t, generalMessageID, generalChannelID, seaFileID)
+	post(t, gpsMessageID, gpsChannelID, sunsetFileID, seaFileID)
+
+	first := nextStreamEvent(t, gps)
+	assert.Equal(t, first.Event, "image")
+	assert.Equal(t, first.Hit.ID, sunsetFileID)
+	assert.Equal(t, first.Hit.MessageID, gpsMessageID)
+	assert.Equal(t, first.Hit.ChannelID, gpsChannelID)
+	second := nextStreamEvent(t, gps)
+	assert.Equal(t, second.Hit.ID, seaFileID)
+	assert.Equal(t, second.ID, first.ID)
+
+	t.Run("stamp filter matches after stamps are added", func(t *testing.T) {
+		stamped := openStream(t, app.URL, "?in="+gpsChannelID+"&stamp=camera", nil)
+		post(t, stampedMessageID, gpsChannelID, seaFileID)
+		assertNoStreamEvent(t, stamped)
+
+		now := time.Now()
+		status, err := sender.BotMessageStampsUpdated(ctx, stampedMessageID, []traq.MessageStamp{
+			{UserID: bobID, StampID: cameraStampID, Count: 2, CreatedAt: now, UpdatedAt: now},
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, status, http.StatusNoContent)
+
+		ev := nextStreamEvent(t, stamped)
+		assert.Equal(t, ev.Hit.MessageID, stampedMessageID)
+		assert.Equal(t, ev.Hit.StampCount, 2)
+	})
+
+	t.Run("resume from Last-Event-ID", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("Last-Event-ID", strconv.FormatUint(first.ID-1, 10))
+		resumed := openStream(t, app.URL, "?in="+gpsChannelID, header)
+
+		ev := nextStreamEvent(t, resumed)
+		assert.Equal(t, ev.ID, first.ID)
+		assert.Equal(t, ev.Hit.MessageID, gpsMessageID)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		rec := doRequest(t, http.MethodGet, "/api/v1/images/stream", "")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+		rec = doRequest(t, http.MethodGet, "/api/v1/images/stream", "", withToken(aliceToken), withHeader("Last-Event-ID", "x"))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+}
+
+// ポーリングで見つけた新しい投稿がフィードに配信される
+func TestImageStreamPolling(t *testing.T) {
+	t.Parallel()
+	// 投稿を追加しても他のテストに影響しないよう、専用の traQ を使う
+	fx, err := traqtest.LoadFixtures("testdata/traq.json")
+	assert.NilError(t, err)
+	srv := traqtest.NewServer(fx)
+	defer srv.Close()
+	traqClient, err := traq.New(srv.URL, nil)
+	assert.NilError(t, err)
+
+	hub := feed.NewHub(10)
+	sub, _ := hub.Subscribe(0)
+	defer hub.Unsubscribe(sub)
+	ix := indexer.New(repository.New(appDB), traqClient, hub, indexer.Config{Token: botToken})
+
+	// 起動前の投稿は配信しない
+	n, err := ix.Poll(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	const messageID = "7d6c5b4a-1a2b-4c3d-8e9f-0a1b2c3d4e30"
+	now := time.Now()
+	srv.AddMessage(traq.Message{
+		ID:        messageID,
+		UserID:    bobID,
+		ChannelID: gpsChannelID,
+		Content:   "新着\n" + srv.FileURL(sunsetFileID),
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	n, err = ix.Poll(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	select {
+	case ev := <-sub.C:
+		assert.Equal(t, ev.Message.Id.String(), messageID)
+	default:
+		t.Fatal("no event was published")
+	}
+
+	// 取り込み済みの投稿は取り込み直さない
+	n, err = ix.Poll(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+}
@@ -0,0 +1,271 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+type imageSearchResponse struct {
+	TotalHits int      `json:"totalHits"`
+	Hits      []string `json:"hits"`
+}
+
+func TestSearchImages(t *testing.T) {
+	tests := map[string]struct {
+		query     string
+		wantTotal int // 0 は totalHits を返さないこと（スタンプで絞り込んだ場合）を表す
+		wantHits  []string
+	}{
+		"all": {
+			query:     "",
+			wantTotal: 3,
+			wantHits:  []string{sunsetCopyFileID, seaFileID, sunsetFileID, sunsetFileID},
+		},
+		"oldest first": {
+			query:     "?sort=createdAt&limit=2",
+			wantTotal: 3,
+			wantHits:  []string{sunsetFileID, sunsetFileID},
+		},
+		"stamp": {
+			query:    "?stampId=" + goodStampID,
+			wantHits: []string{sunsetFileID},
+		},
+		"stamp name": {
+			query:    "?stamp=good",
+			wantHits: []string{sunsetFileID},
+		},
+		"any stamp": {
+			query:    "?stamp=good&stamp=:camera:",
+			wantHits: []string{sunsetCopyFileID, seaFileID, sunsetFileID},
+		},
+		"all stamps": {
+			query:    "?stampId=" + goodStampID + "&stampId=" + cameraStampID + "&stampMode=all",
+			wantHits: []string{},
+		},
+		"min stamp count": {
+			query:    "?stamp=good&stamp=camera&minStampCount=3",
+			wantHits: []string{sunsetCopyFileID, seaFileID},
+		},
+		"min total stamp count": {
+			query:    "?minStampCount=1",
+			wantHits: []string{sunsetCopyFileID, seaFileID, sunsetFileID},
+		},
+		"from": {
+			query:     "?from=" + bobID,
+			wantTotal: 1,
+			wantHits:  []string{sunsetFileID},
+		},
+		"channel": {
+			query:     "?in=" + generalChannelID + "&bot=false",
+			wantTotal: 1,
+			wantHits:  []string{sunsetFileID},
+		},
+		"channel path": {
+			query:     "?channel=%23general&bot=false",
+			wantTotal: 1,
+			wantHits:  []string{sunsetFileID},
+		},
+		"bot": {
+			query:     "?bot=true",
+			wantTotal: 1,
+			wantHits:  []string{sunsetCopyFileID, seaFileID},
+		},
+		"word": {
+			query:     "?word=" + "%E5%A4%95%E7%84%BC%E3%81%91", // 夕焼け
+			wantTotal: 1,
+			wantHits:  []string{sunsetFileID},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			rec := doRequest(t, http.MethodGet, "/api/v1/images"+tt.query, "", withToken(aliceToken))
+			assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+			var res struct {
+				TotalHits *int     `json:"totalHits"`
+				Hits      []string `json:"hits"`
+			}
+			assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			if tt.wantTotal == 0 {
+				assert.Assert(t, res.TotalHits == nil)
+			} else {
+				assert.Assert(t, res.TotalHits != nil)
+				assert.Equal(t, *res.TotalHits, tt.wantTotal)
+			}
+			assert.DeepEqual(t, res.Hits, tt.wantHits)
+		})
+	}
+
+	t.Run("unknown stamp", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/images?stamp=nothing", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("invalid stamp mode", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/images?stamp=good&stampMode=none", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("unknown channel", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/images?channel=nowhere", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/images", "")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+}
+
+func TestSearchImagesPagination(t *testing.T) {
+	type page struct {
+		TotalHits  int      `json:"totalHits"`
+		Hits       []string `json:"hits"`
+		NextCursor *string  `json:"nextCursor"`
+	}
+	get := func(t *testing.T, query string) page {
+		t.Helper()
+		rec := doRequest(t, http.MethodGet, "/api/v1/images"+query, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		var res page
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+
+	t.Run("without filter", func(t *testing.T) {
+		t.Parallel()
+		first := get(t, "?sort=createdAt&limit=2")
+		assert.DeepEqual(t, first.Hits, []string{sunsetFileID, sunsetFileID})
+		assert.Assert(t, first.NextCursor != nil)
+
+		second := get(t, "?sort=createdAt&limit=2&cursor="+*first.NextCursor)
+		assert.DeepEqual(t, second.Hits, []string{sunsetCopyFileID, seaFileID})
+		assert.Assert(t, second.NextCursor == nil)
+	})
+
+	t.Run("fills the page after stamp filtering", func(t *testing.T) {
+		t.Parallel()
+		// 新しい順に M4(camera), M2(なし), M1(good)。2件目を探すために M2 を読み飛ばす
+		first := get(t, "?stamp=good&stamp=camera&limit=1")
+		assert.DeepEqual(t, first.Hits, []string{sunsetCopyFileID, seaFileID})
+		assert.Assert(t, first.NextCursor != nil)
+
+		second := get(t, "?stamp=good&stamp=camera&limit=1&cursor="+*first.NextCursor)
+		assert.DeepEqual(t, second.Hits, []string{sunsetFileID})
+		assert.Assert(t, second.NextCursor == nil)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/images?cursor=not-a-cursor", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+}
+
+func TestSearchImagesRichHits(t *testing.T) {
+	t.Parallel()
+	rec := doRequest(t, http.MethodGet, "/api/v1/images?hitFormat=rich", "", withToken(aliceToken))
+	assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+	type stamp struct {
+		StampID string `json:"stampId"`
+		Count   int    `json:"count"`
+	}
+	var res struct {
+		TotalHits int `json:"totalHits"`
+		Hits      []struct {
+			ID         string  `json:"id"`
+			MessageID  string  `json:"messageId"`
+			UserID     string  `json:"userId"`
+			ChannelID  string  `json:"channelId"`
+			Stamps     []stamp `json:"stamps"`
+			StampCount int     `json:"stampCount"`
+		} `json:"hits"`
+	}
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, res.TotalHits, 3)
+
+	// 転載された画像は新しい方のメッセージの1件にまとめる
+	ids := make([]string, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		ids = append(ids, hit.ID)
+	}
+	assert.DeepEqual(t, ids, []string{sunsetCopyFileID, seaFileID, sunsetFileID})
+
+	sea := res.Hits[1]
+	assert.Equal(t, sea.StampCount, 3)
+	assert.DeepEqual(t, sea.Stamps, []stamp{{StampID: cameraStampID, Count: 3}})
+
+	sunset := res.Hits[2]
+	assert.Equal(t, sunset.MessageID, repostMessageID)
+	assert.Equal(t, sunset.UserID, bobID)
+	assert.Equal(t, sunset.StampCount, 0)
+
+	t.Run("invalid format", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/images?hitFormat=xml", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+}
+
+func TestGetImage(t *testing.T) {
+	t.Run("oldest message", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/images/"+sunsetFileID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+		var m struct {
+			ID     string `json:"id"`
+			UserID string `json:"userId"`
+		}
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+		assert.Equal(t, m.ID, sunsetMessageID)
+		assert.Equal(t, m.UserID, aliceID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/images/00000000-0000-0000-0000-000000000000", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+	})
+}
+
+func TestGetImageMessages(t *testing.T) {
+	t.Parallel()
+	rec := doRequest(t, http.MethodGet, "/api/v1/images/"+sunsetFileID+"/messages", "", withToken(aliceToken))
+	assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+	type message struct {
+		Kind   string `json:"kind"`
+		ID     string `json:"id"`
+		Quotes *struct {
+			TotalHits int       `json:"totalHits"`
+			Hits      []message `json:"hits"`
+		} `json:"quotes"`
+	}
+	var res struct {
+		TotalHits int       `json:"totalHits"`
+		Hits      []message `json:"hits"`
+	}
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, res.TotalHits, 2)
+	assert.Equal(t, len(res.Hits), 2)
+
+	assert.Equal(t, res.Hits[0].Kind, "original")
+	assert.Equal(t, res.Hits[0].ID, sunsetMessageID)
+	assert.Equal(t, res.Hits[0].Quotes.TotalHits, 1)
+	assert.Equal(t, res.Hits[0].Quotes.Hits[0].Kind, "quote")
+	assert.Equal(t, res.Hits[0].Quotes.Hits[0].ID, quoteMessageID)
+
+	assert.Equal(t, res.Hits[1].Kind, "repost")
+	assert.Equal(t, res.Hits[1].ID, repostMessageID)
+	assert.Equal(t, res.Hits[1].Quotes.TotalHits, 0)
+}
@@ -0,0 +1,94 @@
+package integration_tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/internal/indexer"
+	"github.com/traP-jp/1m25_10/backend/internal/repository"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLocalImageIndex(t *testing.T) {
+	traqClient, err := traq.New(fakeTraq.URL, nil)
+	assert.NilError(t, err)
+	repo := repository.New(appDB)
+
+	// 画像付きの公開チャンネルのメッセージ M1, M2, M4 を索引する
+	ix := indexer.New(repo, traqClient, nil, indexer.Config{Token: botToken})
+	n, err := ix.RunOnce(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, n, 3)
+
+	// 前回の続きから取得するので、新しいメッセージが無ければ何もしない
+	n, err = ix.RunOnce(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	// 直近のメッセージはスタンプを反映するために取得し直す
+	n, err = indexer.New(repo, traqClient, nil, indexer.Config{Token: botToken, RecrawlWindow: time.Hour}).RunOnce(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	tests := map[string]struct {
+		query    string
+		wantHits []string
+	}{
+		"channel": {
+			query:    "&in=" + generalChannelID,
+			wantHits: []string{sunsetCopyFileID, seaFileID, sunsetFileID},
+		},
+		"channel path": {
+			query:    "&channel=%23random",
+			wantHits: []string{sunsetFileID},
+		},
+		"user": {
+			query:    "&from=" + bobID,
+			wantHits: []string{sunsetFileID},
+		},
+		"before": {
+			query:    "&before=2025-01-02T00:00:00Z",
+			wantHits: []string{sunsetFileID},
+		},
+		"stamp": {
+			query:    "&stamp=good",
+			wantHits: []string{sunsetFileID},
+		},
+		"min stamp count": {
+			query:    "&stamp=camera&stamp=good&minStampCount=3",
+			wantHits: []string{sunsetCopyFileID, seaFileID},
+		},
+		"all stamps": {
+			query:    "&stamp=camera&stamp=good&stampMode=all",
+			wantHits: []string{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := doRequest(t, http.MethodGet, "/api/v1/images?source=local"+tt.query, "", withToken(aliceToken))
+			assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+			var res imageSearchResponse
+			assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, res.TotalHits, len(tt.wantHits))
+			assert.DeepEqual(t, res.Hits, tt.wantHits)
+		})
+	}
+
+	t.Run("invalid user", func(t *testing.T) {
+		rec := doRequest(t, http.MethodGet, "/api/v1/images?source=local&from=alice", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		rec := doRequest(t, http.MethodGet, "/api/v1/images?source=local&in="+generalChannelID, "")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		rec = doRequest(t, http.MethodGet, "/api/v1/images?source=local&color=%23ff8800", "")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+}
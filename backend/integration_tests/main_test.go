@@ -0,0 +1,296 @@
+package integration_tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/cmd/server/server"
+	"github.com/traP-jp/1m25_10/backend/internal/repository"
+	"github.com/traP-jp/1m25_10/backend/internal/session"
+	"github.com/traP-jp/1m25_10/backend/pkg/config"
+	"github.com/traP-jp/1m25_10/backend/pkg/database"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq/traqtest"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	gmsserver "github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	testDBName = "app"
+
+	// testdata/traq.json のフィクスチャ
+	aliceID    = "9e1c5f4c-6d6a-4b2b-9a42-0a6c1f0b1a01"
+	bobID      = "9e1c5f4c-6d6a-4b2b-9a42-0a6c1f0b1a02"
+	aliceToken = "alice-token"
+	bobToken   = "bob-token"
+	botToken   = "camera-bot-token"
+
+	botVerificationToken = "bot-verification-token"
+	// セッションのトークンを暗号化する鍵（hex で32バイト）
+	sessionEncryptionKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+	generalChannelID    = "5b0e7c1e-3f5a-4d8e-8d1b-2f6a0c7b2c01"
+	gpsChannelID        = "5b0e7c1e-3f5a-4d8e-8d1b-2f6a0c7b2c03"
+	aliceTimesChannelID = "5b0e7c1e-3f5a-4d8e-8d1b-2f6a0c7b2c05"
+
+	sunsetFileID     = "3f2a1b0c-8e7d-4c6b-9a5f-1e2d3c4b5a01"
+	sunsetCopyFileID = "3f2a1b0c-8e7d-4c6b-9a5f-1e2d3c4b5a02"
+	seaFileID        = "3f2a1b0c-8e7d-4c6b-9a5f-1e2d3c4b5a03"
+
+	sunsetMessageID = "7d6c5b4a-1a2b-4c3d-8e9f-0a1b2c3d4e01"
+	repostMessageID = "7d6c5b4a-1a2b-4c3d-8e9f-0a1b2c3d4e02"
+	quoteMessageID  = "7d6c5b4a-1a2b-4c3d-8e9f-0a1b2c3d4e03"
+
+	goodStampID   = "2c1b0a9f-5e4d-4c3b-8a2f-9e8d7c6b5a01"
+	cameraStampID = "2c1b0a9f-5e4d-4c3b-8a2f-9e8d7c6b5a02"
+)
+
+var (
+	e        *echo.Echo
+	appDB    *sqlx.DB
+	fakeTraq *traqtest.Server
+	fixtures *traqtest.Fixtures
+	sessions *session.Store
+
+	// アクセストークンごとに作ったセッションの Cookie の値
+	sessionSecretsMu sync.Mutex
+	sessionSecrets   = map[string]string{}
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	// DB (インメモリのMySQL互換サーバー)
+	dbAddr, closeDB, err := startDB()
+	if err != nil {
+		log.Printf("failed to start database: %v", err)
+		return 1
+	}
+	defer closeDB()
+
+	// traQ
+	fixtures, err = traqtest.LoadFixtures("testdata/traq.json")
+	if err != nil {
+		log.Printf("failed to load fixtures: %v", err)
+		return 1
+	}
+	fakeTraq = traqtest.NewServer(fixtures)
+	defer fakeTraq.Close()
+
+	cacheDir, err := os.MkdirTemp("", "traq-files-")
+	if err != nil {
+		log.Printf("failed to create cache directory: %v", err)
+		return 1
+	}
+	defer func() { _ = os.RemoveAll(cacheDir) }()
+
+	host, port, _ := net.SplitHostPort(dbAddr)
+	env := map[string]string{
+		"DB_HOST":                     host,
+		"DB_PORT":                     port,
+		"DB_USER":                     "root",
+		"DB_PASS":                     "",
+		"DB_NAME":                     testDBName,
+		"TRAQ_BASE_URL":               fakeTraq.URL,
+		"TRAQ_OAUTH_CLIENT_ID":        "test-client",
+		"TRAQ_OAUTH_REDIRECT_URI":     "http://localhost:8080/api/auth/callback",
+		"FILE_CACHE_DIR":              cacheDir,
+		"TRAQ_BOT_VERIFICATION_TOKEN": botVerificationToken,
+		"TRAQ_SERVICE_TOKEN":          botToken,
+		"SESSION_ENCRYPTION_KEY":      sessionEncryptionKey,
+		// 再試行の待ち時間と circuit breaker の開いている期間をテスト向けに短くする
+		"TRAQ_RETRY_BASE_DELAY":        "1ms",
+		"TRAQ_CIRCUIT_OPEN_DURATION":   "200ms",
+		"TRAQ_MAX_CONCURRENT_REQUESTS": "4",
+	}
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			log.Printf("failed to set %s: %v", k, err)
+			return 1
+		}
+	}
+
+	db, err := database.Setup(config.MySQL())
+	if err != nil {
+		log.Printf("failed to setup database: %v", err)
+		return 1
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("failed to close DB: %v", err)
+		}
+	}()
+	// インメモリDBはコミット時にテーブル全体を書き戻すため、並行するトランザクションの更新が失われる。
+	// 接続を1本にしてクエリを直列化する
+	db.SetMaxOpenConns(1)
+	appDB = db
+
+	key, err := session.ParseKey(sessionEncryptionKey)
+	if err != nil {
+		log.Printf("failed to parse session key: %v", err)
+		return 1
+	}
+	sessions, err = session.NewStore(repository.New(db), key)
+	if err != nil {
+		log.Printf("failed to create session store: %v", err)
+		return 1
+	}
+
+	s, err := server.Inject(db)
+	if err != nil {
+		log.Printf("failed to inject dependencies: %v", err)
+		return 1
+	}
+	e = echo.New()
+	s.SetupRoot(e)
+
+	return m.Run()
+}
+
+// startDB は空のデータベースを持つMySQL互換サーバーを起動し、そのアドレスを返す
+func startDB() (string, func(), error) {
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	db := memory.NewDatabase(testDBName)
+	// 外部キー制約に主キーのインデックスが必要
+	db.BaseDatabase.EnablePrimaryKeyIndexes()
+	pro := memory.NewDBProvider(db)
+	engine := sqle.NewDefault(pro)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	cfg := gmsserver.Config{
+		Protocol: "tcp",
+		Address:  l.Addr().String(),
+		Listener: l,
+	}
+	srv, err := gmsserver.NewServer(cfg, engine, sql.NewContext, memory.NewSessionBuilder(pro), nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create server: %w", err)
+	}
+	go func() {
+		if err := srv.Start(); err != nil {
+			log.Printf("database server stopped: %v", err)
+		}
+	}()
+
+	return l.Addr().String(), func() { _ = srv.Close() }, nil
+}
+
+type requestOption func(*http.Request)
+
+// withToken はtraQのアクセストークンのセッションをCookieに設定する
+func withToken(token string) requestOption {
+	return func(req *http.Request) {
+		req.AddCookie(sessionCookie(token))
+	}
+}
+
+// sessionCookie はアクセストークンのセッションの Cookie を返す。セッションはトークンごとに1度だけ作る
+func sessionCookie(token string) *http.Cookie {
+	sessionSecretsMu.Lock()
+	defer sessionSecretsMu.Unlock()
+	secret, ok := sessionSecrets[token]
+	if !ok {
+		// フィクスチャに無いトークンのユーザーIDは分からないので、空のIDにする
+		userID, _ := uuid.Parse(fixtures.Tokens[token])
+		var err error
+		secret, _, err = sessions.Create(context.Background(), token, userID, "integration-tests", time.Now().Add(time.Hour))
+		if err != nil {
+			panic(fmt.Sprintf("failed to create session: %v", err))
+		}
+		sessionSecrets[token] = secret
+	}
+	return &http.Cookie{Name: "traq-auth-session", Value: secret}
+}
+
+// withUser は部員認証のユーザー名(X-Forwarded-User)を設定する
+func withUser(name string) requestOption {
+	return func(req *http.Request) {
+		req.Header.Set("X-Forwarded-User", name)
+	}
+}
+
+func withCookies(cookies []*http.Cookie) requestOption {
+	return func(req *http.Request) {
+		for _, ck := range cookies {
+			req.AddCookie(ck)
+		}
+	}
+}
+
+func withHeader(key, value string) requestOption {
+	return func(req *http.Request) {
+		req.Header.Set(key, value)
+	}
+}
+
+func doRequest(t *testing.T, method, path, body string, opts ...requestOption) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequestWithContext(context.Background(), method, path, r)
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// fixtureFile はフィクスチャのファイルを返す
+func fixtureFile(t *testing.T, id string) traqtest.File {
+	t.Helper()
+	for _, f := range fixtures.Files {
+		if f.ID == id {
+			return f
+		}
+	}
+	t.Fatalf("fixture file not found: %s", id)
+	return traqtest.File{}
+}
+
+// errorResponse はすべてのエンドポイントで共通のエラーレスポンス
+type errorResponse struct {
+	Error     string          `json:"error"`
+	Message   string          `json:"message"`
+	Details   json.RawMessage `json:"details"`
+	RequestID string          `json:"request_id"`
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) errorResponse {
+	t.Helper()
+	var res errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("invalid error response %q: %v", rec.Body.String(), err)
+	}
+	return res
+}
@@ -0,0 +1,149 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+type sessionResponse struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Current   bool      `json:"current"`
+}
+
+// login は OAuth のフローでログインし、セッションの Cookie を返す（traQ の fake は alice として認可する）
+func login(t *testing.T, userAgent string) *http.Cookie {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, "/api/auth/request", "")
+	assert.Equal(t, rec.Code, http.StatusFound)
+	tempCookies := rec.Result().Cookies()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Get(rec.Header().Get("Location"))
+	assert.NilError(t, err)
+	assert.NilError(t, resp.Body.Close())
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	assert.NilError(t, err)
+
+	rec = doRequest(t, http.MethodGet, callback.RequestURI(), "", withCookies(tempCookies), withHeader("User-Agent", userAgent))
+	assert.Equal(t, rec.Code, http.StatusFound, rec.Body.String())
+	for _, ck := range rec.Result().Cookies() {
+		if ck.Name == "traq-auth-session" && ck.Value != "" {
+			return ck
+		}
+	}
+	t.Fatal("session cookie is not set")
+	return nil
+}
+
+func getSessions(t *testing.T, ck *http.Cookie) []sessionResponse {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, "/api/auth/sessions", "", withCookies([]*http.Cookie{ck}))
+	assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+	var res []sessionResponse
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return res
+}
+
+// findSession は一覧から User-Agent の一致するセッションを探す（他のテストのセッションも含まれる）
+func findSession(sessions []sessionResponse, userAgent string) (sessionResponse, bool) {
+	for _, s := range sessions {
+		if s.UserAgent == userAgent {
+			return s, true
+		}
+	}
+	return sessionResponse{}, false
+}
+
+func TestSessions(t *testing.T) {
+	t.Run("list and revoke", func(t *testing.T) {
+		t.Parallel()
+		laptop := login(t, "sessions-test laptop")
+		phone := login(t, "sessions-test phone")
+
+		sessions := getSessions(t, laptop)
+		l, ok := findSession(sessions, "sessions-test laptop")
+		assert.Assert(t, ok)
+		assert.Equal(t, l.UserID, aliceID)
+		assert.Assert(t, l.Current)
+		assert.Assert(t, l.ExpiresAt.After(l.CreatedAt))
+		p, ok := findSession(sessions, "sessions-test phone")
+		assert.Assert(t, ok)
+		assert.Assert(t, !p.Current)
+
+		// 他の端末のセッションを失効させる
+		rec := doRequest(t, http.MethodDelete, "/api/auth/sessions/"+p.ID, "", withCookies([]*http.Cookie{laptop}))
+		assert.Equal(t, rec.Code, http.StatusNoContent)
+		rec = doRequest(t, http.MethodGet, "/api/auth/me", "", withCookies([]*http.Cookie{phone}))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		_, ok = findSession(getSessions(t, laptop), "sessions-test phone")
+		assert.Assert(t, !ok)
+
+		rec = doRequest(t, http.MethodDelete, "/api/auth/sessions/"+p.ID, "", withCookies([]*http.Cookie{laptop}))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+
+		// 自分のセッションを失効させると Cookie も消える
+		rec = doRequest(t, http.MethodDelete, "/api/auth/sessions/"+l.ID, "", withCookies([]*http.Cookie{laptop}))
+		assert.Equal(t, rec.Code, http.StatusNoContent)
+		cleared := false
+		for _, ck := range rec.Result().Cookies() {
+			if ck.Name == "traq-auth-session" && ck.MaxAge < 0 {
+				cleared = true
+			}
+		}
+		assert.Assert(t, cleared)
+		rec = doRequest(t, http.MethodGet, "/api/auth/me", "", withCookies([]*http.Cookie{laptop}))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("logout", func(t *testing.T) {
+		t.Parallel()
+		ck := login(t, "sessions-test logout")
+		rec := doRequest(t, http.MethodGet, "/api/auth/me", "", withCookies([]*http.Cookie{ck}))
+		assert.Equal(t, rec.Code, http.StatusOK)
+
+		rec = doRequest(t, http.MethodPost, "/api/auth/logout", "", withCookies([]*http.Cookie{ck}))
+		assert.Equal(t, rec.Code, http.StatusNoContent)
+		rec = doRequest(t, http.MethodGet, "/api/auth/me", "", withCookies([]*http.Cookie{ck}))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("cannot revoke sessions of other users", func(t *testing.T) {
+		t.Parallel()
+		sessions := getSessions(t, sessionCookie(bobToken))
+		assert.Assert(t, len(sessions) > 0)
+		bobSession := sessions[0]
+		assert.Equal(t, bobSession.UserID, bobID)
+
+		rec := doRequest(t, http.MethodDelete, "/api/auth/sessions/"+bobSession.ID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+		rec = doRequest(t, http.MethodGet, "/api/auth/me", "", withToken(bobToken))
+		assert.Equal(t, rec.Code, http.StatusOK)
+	})
+
+	t.Run("access token cookie is not accepted", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/auth/me", "", withCookies([]*http.Cookie{{Name: "traq-auth-token", Value: aliceToken}}))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/auth/sessions", "")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		rec = doRequest(t, http.MethodGet, "/api/auth/sessions", "", withCookies([]*http.Cookie{{Name: "traq-auth-session", Value: "unknown"}}))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		rec = doRequest(t, http.MethodDelete, "/api/auth/sessions/not-a-uuid", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+}
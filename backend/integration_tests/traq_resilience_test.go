@@ -0,0 +1,216 @@
+package integration_tests
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq/traqtest"
+
+	"github.com/google/uuid"
+	"gotest.tools/v3/assert"
+)
+
+const (
+	searchMessagesPath = "/api/v1/traq/messages?citation=" + sunsetMessageID
+	traqMessagesPath   = "/api/v3/messages"
+)
+
+// traQの障害を注入するとすべてのテストのtraQへのリクエストに影響するため、並行には実行しない
+func TestTraqResilience(t *testing.T) {
+	t.Cleanup(fakeTraq.ClearFaults)
+
+	t.Run("5xx is retried", func(t *testing.T) {
+		fakeTraq.InjectFault(traqtest.Fault{PathPrefix: traqMessagesPath, Status: http.StatusServiceUnavailable, Count: 2})
+		before := fakeTraq.Requests(traqMessagesPath)
+
+		rec := doRequest(t, http.MethodGet, searchMessagesPath, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		assert.Equal(t, fakeTraq.Requests(traqMessagesPath)-before, 3)
+	})
+
+	t.Run("Retry-After is honored", func(t *testing.T) {
+		fakeTraq.InjectFault(traqtest.Fault{PathPrefix: traqMessagesPath, Status: http.StatusTooManyRequests, RetryAfter: "1", Count: 1})
+
+		start := time.Now()
+		rec := doRequest(t, http.MethodGet, searchMessagesPath, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		assert.Assert(t, time.Since(start) >= time.Second)
+	})
+
+	t.Run("too long Retry-After is not waited for", func(t *testing.T) {
+		fakeTraq.InjectFault(traqtest.Fault{PathPrefix: traqMessagesPath, Status: http.StatusTooManyRequests, RetryAfter: "3600", Count: 1})
+		before := fakeTraq.Requests(traqMessagesPath)
+
+		rec := doRequest(t, http.MethodGet, searchMessagesPath, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusTooManyRequests)
+		assert.Equal(t, fakeTraq.Requests(traqMessagesPath)-before, 1)
+	})
+
+	t.Run("circuit opens while traQ is down", func(t *testing.T) {
+		fakeTraq.InjectFault(traqtest.Fault{PathPrefix: traqMessagesPath, Status: http.StatusInternalServerError, Count: -1})
+
+		// 再試行を含めて失敗が閾値に達すると 503 になる
+		rec := doRequest(t, http.MethodGet, searchMessagesPath, "", withToken(aliceToken))
+		for i := 0; i < 3 && rec.Code != http.StatusServiceUnavailable; i++ {
+			assert.Equal(t, rec.Code, http.StatusBadGateway)
+			rec = doRequest(t, http.MethodGet, searchMessagesPath, "", withToken(aliceToken))
+		}
+		assert.Equal(t, rec.Code, http.StatusServiceUnavailable, rec.Body.String())
+		assert.Equal(t, decodeError(t, rec).Error, "traq_unavailable")
+
+		// 開いている間はtraQにリクエストを送らない
+		before := fakeTraq.Requests(traqMessagesPath)
+		rec = doRequest(t, http.MethodGet, searchMessagesPath, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusServiceUnavailable)
+		assert.Equal(t, fakeTraq.Requests(traqMessagesPath), before)
+
+		// traQが復旧すれば、一定時間後に試しに送ったリクエストが成功して閉じる
+		fakeTraq.ClearFaults()
+		time.Sleep(300 * time.Millisecond)
+		rec = doRequest(t, http.MethodGet, searchMessagesPath, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		rec = doRequest(t, http.MethodGet, "/api/v1/traq/users/"+bobID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+	})
+
+	t.Run("resizing while traQ is down", func(t *testing.T) {
+		t.Cleanup(func() {
+			// 開いた circuit を閉じておく（キャッシュされない traQ へのリクエストを試しに送る）
+			fakeTraq.ClearFaults()
+			time.Sleep(300 * time.Millisecond)
+			rec := doRequest(t, http.MethodGet, searchMessagesPath, "", withToken(aliceToken))
+			assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+		})
+		// メタデータの確認は通し、元画像の取得中に他のリクエストの失敗で circuit が開くようにする
+		fakeTraq.InjectFault(traqtest.Fault{PathPrefix: "/api/v3/files/" + seaFileID + "/meta", Count: -1})
+		fakeTraq.InjectFault(traqtest.Fault{PathPrefix: "/api/v3/files/" + seaFileID, Status: http.StatusInternalServerError, Delay: 200 * time.Millisecond, Count: -1})
+		fakeTraq.InjectFault(traqtest.Fault{PathPrefix: traqMessagesPath, Status: http.StatusInternalServerError, Count: -1})
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(50 * time.Millisecond)
+			for range 2 {
+				doRequest(t, http.MethodGet, searchMessagesPath, "", withToken(aliceToken))
+			}
+		}()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/files/"+seaFileID+"?w=123", "", withToken(aliceToken))
+		wg.Wait()
+		assert.Equal(t, rec.Code, http.StatusServiceUnavailable, rec.Body.String())
+		assert.Assert(t, strings.Contains(rec.Body.String(), "traq_unavailable"), rec.Body.String())
+	})
+}
+
+// traQの応答を遅らせるため、並行には実行しない
+func TestTraqRequestCoalescing(t *testing.T) {
+	t.Cleanup(fakeTraq.ClearFaults)
+	fakeTraq.InjectFault(traqtest.Fault{PathPrefix: traqMessagesPath, Delay: 200 * time.Millisecond, Count: -1})
+
+	// paths のリクエストを同時に送り、それぞれのステータスを返す
+	requestAll := func(t *testing.T, paths []string, tokens []string) []int {
+		codes := make([]int, len(paths))
+		var wg sync.WaitGroup
+		for i := range paths {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes[i] = doRequest(t, http.MethodGet, paths[i], "", withToken(tokens[i])).Code
+			}()
+		}
+		wg.Wait()
+		return codes
+	}
+
+	t.Run("identical requests are sent once per token", func(t *testing.T) {
+		paths := make([]string, 10)
+		tokens := make([]string, 10)
+		for i := range paths {
+			paths[i] = searchMessagesPath
+			tokens[i] = aliceToken
+			if i%2 == 1 {
+				tokens[i] = bobToken
+			}
+		}
+		before := fakeTraq.Requests(traqMessagesPath)
+
+		for _, code := range requestAll(t, paths, tokens) {
+			assert.Equal(t, code, http.StatusOK)
+		}
+		assert.Equal(t, fakeTraq.Requests(traqMessagesPath)-before, 2)
+	})
+
+	t.Run("concurrent requests are queued", func(t *testing.T) {
+		paths := make([]string, 10)
+		tokens := make([]string, 10)
+		for i := range paths {
+			paths[i] = searchMessagesPath + "&offset=" + strconv.Itoa(i)
+			tokens[i] = aliceToken
+		}
+		fakeTraq.PeakConcurrentRequests()
+
+		for _, code := range requestAll(t, paths, tokens) {
+			assert.Equal(t, code, http.StatusOK)
+		}
+		// TestMain で設定した TRAQ_MAX_CONCURRENT_REQUESTS を超えない
+		assert.Equal(t, fakeTraq.PeakConcurrentRequests(), 4)
+	})
+
+	t.Run("file downloads being read do not hold a slot", func(t *testing.T) {
+		client, err := traq.New(fakeTraq.URL, nil, traq.WithMaxConcurrentRequests(1))
+		assert.NilError(t, err)
+
+		// 本文を読み終えていないダウンロードがあっても、他のリクエストを送れる
+		resp, err := client.GetFile(context.Background(), aliceToken, sunsetFileID, nil)
+		assert.NilError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		assert.Equal(t, resp.StatusCode, http.StatusOK)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		u, err := client.GetUser(ctx, aliceToken, bobID)
+		assert.NilError(t, err)
+		assert.Equal(t, u.Name, "bob")
+	})
+}
+
+// traQの応答を遅らせるため、並行には実行しない
+func TestTraqSharedFetchWithRevokedToken(t *testing.T) {
+	t.Cleanup(fakeTraq.ClearFaults)
+
+	for _, tc := range []struct {
+		name         string
+		upstreamPath string
+		path         string
+	}{
+		{"user", "/api/v3/users/", "/api/v1/traq/users/" + uuid.NewString()},
+		// 知らないチャンネルの取得ではチャンネル一覧を取得し直す
+		{"channel", "/api/v3/channels", "/api/v1/traq/channels/" + uuid.NewString()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeTraq.InjectFault(traqtest.Fault{PathPrefix: tc.upstreamPath, Delay: 200 * time.Millisecond, Count: -1})
+			t.Cleanup(fakeTraq.ClearFaults)
+
+			// 失効したトークンの取得に相乗りしても、自分のトークンで取得し直す
+			var revoked int
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				revoked = doRequest(t, http.MethodGet, tc.path, "", withToken("revoked-token")).Code
+			}()
+			time.Sleep(50 * time.Millisecond)
+			rec := doRequest(t, http.MethodGet, tc.path, "", withToken(aliceToken))
+			wg.Wait()
+
+			assert.Equal(t, revoked, http.StatusUnauthorized)
+			assert.Equal(t, rec.Code, http.StatusNotFound, rec.Body.String())
+		})
+	}
+}
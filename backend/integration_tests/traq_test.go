@@ -0,0 +1,331 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTraqFiles(t *testing.T) {
+	t.Run("original", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/files/"+sunsetFileID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		assert.Equal(t, rec.Header().Get("Content-Type"), "image/png")
+		assert.DeepEqual(t, rec.Body.Bytes(), fixtureFile(t, sunsetFileID).Content)
+	})
+
+	t.Run("thumbnail", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/files/"+seaFileID+"/thumbnail", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		assert.DeepEqual(t, rec.Body.Bytes(), fixtureFile(t, seaFileID).Thumbnail)
+	})
+
+	t.Run("variant", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/files/"+sunsetFileID+"?w=160", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		assert.Equal(t, rec.Header().Get("Content-Type"), "image/png")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/files/00000000-0000-0000-0000-000000000000", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/files/"+sunsetFileID, "")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+}
+
+func TestTraqFileCache(t *testing.T) {
+	t.Parallel()
+	path := "/api/v1/traq/files/" + sunsetCopyFileID
+
+	rec := doRequest(t, http.MethodGet, path, "", withToken(aliceToken))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Header().Get("X-Cache"), "MISS")
+
+	// アクセスを確認済みのユーザーにはtraQへ問い合わせずに返す
+	rec = doRequest(t, http.MethodGet, path, "", withToken(aliceToken))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Header().Get("X-Cache"), "HIT")
+	assert.DeepEqual(t, rec.Body.Bytes(), fixtureFile(t, sunsetCopyFileID).Content)
+
+	// 別のユーザーには条件付きリクエストでアクセスを確認してから返す
+	rec = doRequest(t, http.MethodGet, path, "", withToken(bobToken))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Header().Get("X-Cache"), "REVALIDATED")
+	assert.DeepEqual(t, rec.Body.Bytes(), fixtureFile(t, sunsetCopyFileID).Content)
+
+	// アクセスできないユーザーにはキャッシュを返さない
+	rec = doRequest(t, http.MethodGet, path, "", withToken("invalid"))
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+	rec = doRequest(t, http.MethodGet, "/api/v1/traq/files/cache/stats", "")
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	rec = doRequest(t, http.MethodGet, "/api/v1/traq/files/cache/stats", "", withToken(aliceToken))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	var stats struct {
+		Enabled     bool `json:"enabled"`
+		Hits        int  `json:"hits"`
+		Revalidated int  `json:"revalidated"`
+		Entries     int  `json:"entries"`
+	}
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Assert(t, stats.Enabled)
+	assert.Assert(t, stats.Hits >= 1)
+	assert.Assert(t, stats.Revalidated >= 1)
+	assert.Assert(t, stats.Entries >= 1)
+}
+
+func TestTraqFileConditionalAndRange(t *testing.T) {
+	t.Run("forwarded to traQ", func(t *testing.T) {
+		t.Parallel()
+		path := "/api/v1/traq/files/" + sunsetFileID + "/thumbnail"
+		thumbnail := fixtureFile(t, sunsetFileID).Thumbnail
+
+		rec := doRequest(t, http.MethodGet, path, "", withToken(aliceToken), withHeader("Range", "bytes=0-9"))
+		assert.Equal(t, rec.Code, http.StatusPartialContent)
+		assert.Equal(t, rec.Header().Get("X-Cache"), "MISS")
+		assert.Equal(t, rec.Header().Get("Content-Range"), fmt.Sprintf("bytes 0-9/%d", len(thumbnail)))
+		assert.DeepEqual(t, rec.Body.Bytes(), thumbnail[:10])
+		etag := rec.Header().Get("ETag")
+		assert.Assert(t, etag != "")
+
+		rec = doRequest(t, http.MethodGet, path, "", withToken(aliceToken), withHeader("If-None-Match", etag))
+		assert.Equal(t, rec.Code, http.StatusNotModified)
+		assert.Equal(t, rec.Body.Len(), 0)
+	})
+
+	t.Run("served from cache", func(t *testing.T) {
+		t.Parallel()
+		path := "/api/v1/traq/files/" + seaFileID
+		content := fixtureFile(t, seaFileID).Content
+
+		rec := doRequest(t, http.MethodGet, path, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		etag := rec.Header().Get("ETag")
+		lastModified := rec.Header().Get("Last-Modified")
+
+		rec = doRequest(t, http.MethodGet, path, "", withToken(aliceToken), withHeader("Range", "bytes=10-"))
+		assert.Equal(t, rec.Code, http.StatusPartialContent)
+		assert.Equal(t, rec.Header().Get("X-Cache"), "HIT")
+		assert.Equal(t, rec.Header().Get("Accept-Ranges"), "bytes")
+		assert.Equal(t, rec.Header().Get("Content-Range"), fmt.Sprintf("bytes 10-%d/%d", len(content)-1, len(content)))
+		assert.DeepEqual(t, rec.Body.Bytes(), content[10:])
+
+		rec = doRequest(t, http.MethodGet, path, "", withToken(aliceToken), withHeader("If-None-Match", etag))
+		assert.Equal(t, rec.Code, http.StatusNotModified)
+
+		rec = doRequest(t, http.MethodGet, path, "", withToken(aliceToken), withHeader("If-Modified-Since", lastModified))
+		assert.Equal(t, rec.Code, http.StatusNotModified)
+
+		// 別のユーザーでも、アクセスを確認した上でキャッシュから応答する
+		rec = doRequest(t, http.MethodGet, path, "", withToken(bobToken), withHeader("If-None-Match", etag))
+		assert.Equal(t, rec.Code, http.StatusNotModified)
+		assert.Equal(t, rec.Header().Get("X-Cache"), "REVALIDATED")
+	})
+}
+
+func TestTraqMessages(t *testing.T) {
+	t.Run("search", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/messages?citation="+sunsetMessageID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+		var res struct {
+			TotalHits int `json:"totalHits"`
+			Hits      []struct {
+				ID string `json:"id"`
+			} `json:"hits"`
+		}
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, res.TotalHits, 1)
+		assert.Equal(t, res.Hits[0].ID, quoteMessageID)
+	})
+
+	t.Run("search with stamp filter", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/messages?citation="+sunsetMessageID+"&stamp=good", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+		// 絞り込み前の件数を返さない
+		var res map[string]json.RawMessage
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		_, ok := res["totalHits"]
+		assert.Assert(t, !ok)
+		assert.Equal(t, string(res["hits"]), "[]")
+	})
+
+	t.Run("traQ error is relayed", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/messages?limit=101", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+}
+
+func TestTraqUsers(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/users/"+bobID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+		var u struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &u))
+		assert.Equal(t, u.ID, bobID)
+		assert.Equal(t, u.Name, "bob")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/users/00000000-0000-0000-0000-000000000000", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+	})
+}
+
+func TestTraqUsersBatch(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+		body := fmt.Sprintf(`{"ids":[%q,"00000000-0000-0000-0000-000000000000"],"names":["@alice","BOT_camera","nobody"]}`, aliceID)
+		rec := doRequest(t, http.MethodPost, "/api/v1/traq/users/batch", body, withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+		var res struct {
+			Users []struct {
+				ID          string `json:"id"`
+				Name        string `json:"name"`
+				DisplayName string `json:"displayName"`
+			} `json:"users"`
+			NotFound []string `json:"notFound"`
+		}
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, len(res.Users), 2)
+		assert.Equal(t, res.Users[0].ID, aliceID)
+		assert.Equal(t, res.Users[0].DisplayName, "Alice")
+		assert.Equal(t, res.Users[1].Name, "BOT_camera")
+		assert.DeepEqual(t, res.NotFound, []string{"00000000-0000-0000-0000-000000000000", "nobody"})
+
+		// 2回目以降はキャッシュから返す
+		rec = doRequest(t, http.MethodPost, "/api/v1/traq/users/batch", body, withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		rec = doRequest(t, http.MethodGet, "/api/v1/traq/users/"+aliceID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		assert.Equal(t, fakeTraq.UserRequests(aliceID), 1)
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		t.Parallel()
+		ids := make([]string, 101)
+		for i := range ids {
+			ids[i] = aliceID
+		}
+		b, err := json.Marshal(map[string][]string{"ids": ids})
+		assert.NilError(t, err)
+		rec := doRequest(t, http.MethodPost, "/api/v1/traq/users/batch", string(b), withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodPost, "/api/v1/traq/users/batch", `{"ids":[]}`)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+}
+
+func TestTraqChannels(t *testing.T) {
+	type channel struct {
+		ID       string    `json:"id"`
+		Name     string    `json:"name"`
+		Path     string    `json:"path"`
+		Children []channel `json:"children"`
+	}
+
+	t.Run("tree", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/channels", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+		var res struct {
+			Channels []channel `json:"channels"`
+		}
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, len(res.Channels), 3)
+		gps := res.Channels[1]
+		assert.Equal(t, gps.ID, gpsChannelID)
+		assert.Equal(t, len(gps.Children), 1)
+		assert.Equal(t, len(gps.Children[0].Children), 1)
+		assert.Equal(t, gps.Children[0].Children[0].Path, "gps/times/alice")
+	})
+
+	t.Run("by id", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/channels/"+gpsChannelID, "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+		var ch channel
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &ch))
+		assert.Equal(t, ch.Path, "gps")
+		assert.Equal(t, len(ch.Children), 1)
+		assert.Equal(t, ch.Children[0].Path, "gps/times")
+		assert.Equal(t, len(ch.Children[0].Children), 0)
+	})
+
+	t.Run("by id not found", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/channels/00000000-0000-0000-0000-000000000000", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/channels/resolve?path=%23GPS/times/Alice", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+		var ch channel
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &ch))
+		assert.Equal(t, ch.ID, aliceTimesChannelID)
+		assert.Equal(t, ch.Path, "gps/times/alice")
+	})
+
+	t.Run("resolve not found", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/channels/resolve?path=gps/nowhere", "", withToken(aliceToken))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		t.Parallel()
+		rec := doRequest(t, http.MethodGet, "/api/v1/traq/channels", "")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+}
+
+func TestTraqStamps(t *testing.T) {
+	t.Parallel()
+	rec := doRequest(t, http.MethodGet, "/api/v1/traq/stamps", "", withToken(aliceToken))
+	assert.Equal(t, rec.Code, http.StatusOK, rec.Body.String())
+
+	var res struct {
+		Stamps []struct {
+			ID     string `json:"id"`
+			Name   string `json:"name"`
+			FileID string `json:"fileId"`
+		} `json:"stamps"`
+	}
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, len(res.Stamps), 2)
+	assert.Equal(t, res.Stamps[0].ID, goodStampID)
+	assert.Equal(t, res.Stamps[0].Name, "good")
+	assert.Assert(t, res.Stamps[0].FileID != "")
+}
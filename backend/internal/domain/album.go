@@ -15,16 +15,19 @@ type Album struct {
 	Images      []uuid.UUID `json:"images"`
 	CreatedAt   time.Time   `json:"created_at"`
 	UpdatedAt   time.Time   `json:"updated_at"`
+	// Placeholders maps image IDs to their placeholder info (only images already analyzed)
+	Placeholders map[uuid.UUID]ImagePlaceholder `json:"placeholders,omitempty"`
 }
 
 // AlbumItem represents a simplified album item for list views
 type AlbumItem struct {
-	Id      uuid.UUID `json:"id"`
-	Title   string    `json:"title"`
-	Creator string    `json:"creator"`
-	Images      []uuid.UUID `json:"images"`
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
+	Id           uuid.UUID                      `json:"id"`
+	Title        string                         `json:"title"`
+	Creator      string                         `json:"creator"`
+	Images       []uuid.UUID                    `json:"images"`
+	CreatedAt    time.Time                      `json:"created_at"`
+	UpdatedAt    time.Time                      `json:"updated_at"`
+	Placeholders map[uuid.UUID]ImagePlaceholder `json:"placeholders,omitempty"`
 }
 
 // AlbumFilter represents filtering criteria for albums
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Image represents an image (traQ file) known to the app
+type Image struct {
+	Id                  uuid.UUID  `json:"id"`
+	PHash               *uint64    `json:"-"`
+	Blurhash            *string    `json:"blurhash,omitempty"`
+	DominantColor       *string    `json:"dominant_color,omitempty"`
+	AnalysisVersion     int        `json:"-"`
+	TakenAt             *time.Time `json:"taken_at,omitempty"`
+	CameraModel         *string    `json:"camera_model,omitempty"`
+	Orientation         *int       `json:"orientation,omitempty"`
+	PostedAt            *time.Time `json:"posted_at,omitempty"`
+	MetadataExtractedAt *time.Time `json:"-"`
+}
+
+// ImageAnalysis represents values computed from the traQ thumbnail of an image
+type ImageAnalysis struct {
+	Version       int
+	PHash         uint64
+	Blurhash      string
+	DominantColor string // "#rrggbb"
+	Palette       []PaletteColor
+}
+
+// PaletteColor represents one of the main colors of an image
+type PaletteColor struct {
+	Color  LabColor
+	Weight float64 // fraction of the image in this color, 0-1
+}
+
+// LabColor represents a color in CIE L*a*b*
+type LabColor struct {
+	L, A, B float64
+}
+
+// ImageSearchFilter represents filtering criteria for searching images known to the app.
+// The message filters match images through the local image index.
+type ImageSearchFilter struct {
+	Color     *LabColor
+	Tolerance float64 // maximum CIE76 color difference from Color
+
+	ChannelID     *uuid.UUID
+	UserIDs       []uuid.UUID
+	After         *time.Time // Filter by message created_at
+	Before        *time.Time // Filter by message created_at
+	StampIDs      []uuid.UUID
+	StampMatchAll bool // require all of StampIDs instead of any
+	MinStampCount int  // per stamp in StampIDs, or in total if StampIDs is empty
+
+	Limit  int
+	Offset int
+}
+
+// HasMessageFilter reports whether the filter needs the local image index.
+func (f ImageSearchFilter) HasMessageFilter() bool {
+	return f.ChannelID != nil || len(f.UserIDs) > 0 || f.After != nil || f.Before != nil ||
+		len(f.StampIDs) > 0 || f.MinStampCount > 0
+}
+
+// MatchMessage reports whether an indexed message satisfies the message filters,
+// with the same semantics as the search in the local image index.
+func (f ImageSearchFilter) MatchMessage(m IndexedMessage) bool {
+	if f.ChannelID != nil && *f.ChannelID != m.ChannelID {
+		return false
+	}
+	if len(f.UserIDs) > 0 && !slices.Contains(f.UserIDs, m.UserID) {
+		return false
+	}
+	if f.After != nil && !m.CreatedAt.After(*f.After) {
+		return false
+	}
+	if f.Before != nil && !m.CreatedAt.Before(*f.Before) {
+		return false
+	}
+
+	counts := make(map[uuid.UUID]int, len(m.Stamps))
+	total := 0
+	for _, s := range m.Stamps {
+		counts[s.StampID] += s.Count
+		total += s.Count
+	}
+	minCount := max(f.MinStampCount, 1)
+	if len(f.StampIDs) == 0 {
+		return f.MinStampCount == 0 || total >= minCount
+	}
+	for _, id := range f.StampIDs {
+		ok := counts[id] >= minCount
+		if ok && !f.StampMatchAll {
+			return true
+		}
+		if !ok && f.StampMatchAll {
+			return false
+		}
+	}
+	return f.StampMatchAll
+}
+
+// ImagePlaceholder represents what the frontend needs to paint an image before it loads
+type ImagePlaceholder struct {
+	Blurhash      string `json:"blurhash"`
+	DominantColor string `json:"dominant_color"`
+}
+
+// ImageMetadata represents metadata extracted from the original file and its source message
+type ImageMetadata struct {
+	TakenAt     *time.Time
+	CameraModel *string
+	Orientation *int
+	PostedAt    *time.Time
+}
+
+// DuplicateGroup represents a set of visually identical images
+type DuplicateGroup struct {
+	Images []uuid.UUID `json:"images"`
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IndexedMessage represents a traQ message containing images, as stored in the local image index
+type IndexedMessage struct {
+	Id        uuid.UUID
+	ChannelID uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Images    []uuid.UUID // in order of appearance in the content
+	Stamps    []IndexedStamp
+}
+
+// IndexedStamp represents stamps a user put on an indexed message
+type IndexedStamp struct {
+	StampID uuid.UUID
+	UserID  uuid.UUID
+	Count   int
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session represents a login session of a traQ user
+type Session struct {
+	Id        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// StoredSession represents a session as stored in the database.
+// The traQ access token is kept only in encrypted form.
+type StoredSession struct {
+	Session
+	// SecretHash is the SHA-256 of the secret in the session cookie (hex encoded)
+	SecretHash     string
+	EncryptedToken []byte
+}
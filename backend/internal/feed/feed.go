@@ -0,0 +1,172 @@
+// Package feed は新しく投稿された画像付きメッセージを、ライブフィード (GET /api/v1/images/stream) の購読者に配信します。
+// 直近のイベントを保持しておき、再接続した購読者には Last-Event-ID 以降のイベントを送り直します。
+package feed
+
+import (
+	"sync"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// 購読者ごとに溜めておけるイベントの数。溢れた購読者は切断される（再接続すれば Last-Event-ID から再開できる）
+	subscriberBuffer = 64
+	// 起動より前に投稿されたメッセージは新しい投稿として扱わない。起動直後に届いた少し前の投稿は許す
+	startupGrace = time.Minute
+)
+
+// Event is a message with images pushed to the live feed.
+// A message is pushed again (with a new event ID) when its stamps change.
+type Event struct {
+	// ID increases with every event, also across restarts of the server.
+	ID      uint64
+	Message domain.IndexedMessage
+}
+
+// Subscription receives the events published after it was created.
+// C is closed when the subscriber falls behind or unsubscribes.
+type Subscription struct {
+	C  <-chan Event
+	ch chan Event
+}
+
+// Hub fans out events to subscriptions and keeps the latest events for resuming.
+// It is safe for concurrent use.
+type Hub struct {
+	mu     sync.Mutex
+	size   int
+	events []Event // 古い順
+	// 保持しているイベントのメッセージの最新の状態と、そのメッセージのイベントの数
+	messages map[uuid.UUID]domain.IndexedMessage
+	refs     map[uuid.UUID]int
+	nextID   uint64
+	since    time.Time
+	subs     map[*Subscription]struct{}
+}
+
+// NewHub creates a Hub that keeps the latest size events.
+func NewHub(size int) *Hub {
+	now := time.Now()
+	return &Hub{
+		size:     size,
+		messages: make(map[uuid.UUID]domain.IndexedMessage),
+		refs:     make(map[uuid.UUID]int),
+		// 再起動しても ID が減らないよう、起動時刻から数え始める
+		nextID: uint64(now.UnixMicro()),
+		since:  now.Add(-startupGrace),
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish pushes newly posted messages. Messages posted before the hub was created are ignored,
+// and messages that were already pushed are pushed again only if their stamps changed.
+func (h *Hub) Publish(msgs ...domain.IndexedMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, m := range msgs {
+		if m.CreatedAt.Before(h.since) {
+			continue
+		}
+		if prev, ok := h.messages[m.Id]; ok && sameStamps(prev.Stamps, m.Stamps) {
+			continue
+		}
+		h.push(m)
+	}
+}
+
+// UpdateStamps pushes a message again with new stamps. It does nothing if the message
+// is not among the latest events.
+func (h *Hub) UpdateStamps(messageID uuid.UUID, stamps []domain.IndexedStamp) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m, ok := h.messages[messageID]
+	if !ok || sameStamps(m.Stamps, stamps) {
+		return
+	}
+	m.Stamps = stamps
+	h.push(m)
+}
+
+// Subscribe starts a subscription. If lastEventID is not 0, the kept events after it are
+// returned so that a reconnecting subscriber does not miss them.
+func (h *Hub) Subscribe(lastEventID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var backlog []Event
+	if lastEventID != 0 {
+		for _, e := range h.events {
+			if e.ID > lastEventID {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+	ch := make(chan Event, subscriberBuffer)
+	s := &Subscription{C: ch, ch: ch}
+	h.subs[s] = struct{}{}
+	return s, backlog
+}
+
+// Unsubscribe ends a subscription.
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(s)
+}
+
+func (h *Hub) push(m domain.IndexedMessage) {
+	e := Event{ID: h.nextID, Message: m}
+	h.nextID++
+
+	h.events = append(h.events, e)
+	h.messages[m.Id] = m
+	h.refs[m.Id]++
+	for len(h.events) > h.size {
+		old := h.events[0]
+		h.events = h.events[1:]
+		if h.refs[old.Message.Id]--; h.refs[old.Message.Id] <= 0 {
+			delete(h.refs, old.Message.Id)
+			delete(h.messages, old.Message.Id)
+		}
+	}
+
+	for s := range h.subs {
+		select {
+		case s.ch <- e:
+		default:
+			// 追いつけない購読者は切断する
+			h.remove(s)
+		}
+	}
+}
+
+func (h *Hub) remove(s *Subscription) {
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// sameStamps はユーザーごとのスタンプの個数が同じかを返す（順序は問わない）
+func sameStamps(a, b []domain.IndexedStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	type key struct{ stamp, user uuid.UUID }
+	counts := make(map[key]int, len(a))
+	for _, s := range a {
+		counts[key{s.StampID, s.UserID}] += s.Count
+	}
+	for _, s := range b {
+		counts[key{s.StampID, s.UserID}] -= s.Count
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
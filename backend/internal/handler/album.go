@@ -66,17 +66,37 @@ func (h *Handler) GetAlbums(c echo.Context) error {
 		Offset:     offset,
 	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get albums").SetInternal(err)
+	}
+
+	imageIDs := make([]uuid.UUID, 0)
+	for _, a := range albums {
+		imageIDs = append(imageIDs, a.Images...)
+	}
+	placeholders, err := h.imagePlaceholders(c.Request().Context(), getSessionToken(c), imageIDs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image placeholders").SetInternal(err)
+	}
+	for i := range albums {
+		albums[i].Placeholders = pickPlaceholders(placeholders, albums[i].Images)
 	}
 
 	return c.JSON(http.StatusOK, albums)
 }
 
+// GET /api/v1/albums/:id
+// query: sort (optional, "taken_at": 撮影日時順。撮影日時が無い画像は投稿日時で代用)
+// メタデータを抽出していない画像はバックグラウンドで抽出する。抽出が終わるまでは画像の索引にある投稿日時で並べ、
+// それも無ければ末尾に元の順序で並べる
 func (h *Handler) GetAlbum(c echo.Context) error {
 	albumID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid album ID")
 	}
+	sortKey := c.QueryParam("sort")
+	if sortKey != "" && sortKey != "taken_at" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid sort")
+	}
 	album, err := h.repo.GetAlbum(c.Request().Context(), albumID)
 	if err != nil {
 		if err == domain.ErrNotFound {
@@ -84,6 +104,21 @@ func (h *Handler) GetAlbum(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve album")
 	}
+
+	if sortKey == "taken_at" {
+		// ログイン済みなら未抽出の画像のメタデータをバックグラウンドでtraQから取得する
+		images, err := h.getImageMetadata(c.Request().Context(), getSessionToken(c), album.Images)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image metadata").SetInternal(err)
+		}
+		sortImagesByTakenAt(album.Images, images)
+	}
+
+	placeholders, err := h.imagePlaceholders(c.Request().Context(), getSessionToken(c), album.Images)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image placeholders").SetInternal(err)
+	}
+	album.Placeholders = placeholders
 	return c.JSON(http.StatusOK, album)
 }
 
@@ -93,6 +128,7 @@ func (h *Handler) PostAlbum(c echo.Context) error {
 		Title       string   `json:"title"`
 		Description string   `json:"description"`
 		Images      []string `json:"images"`
+		Dedupe      bool     `json:"dedupe"`
 	})
 	if err := c.Bind(req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
@@ -116,6 +152,15 @@ func (h *Handler) PostAlbum(c echo.Context) error {
 		images = append(images, id)
 	}
 
+	// 見た目が同一の画像を除外（オプション）
+	if req.Dedupe {
+		deduped, err := h.dedupeRequestImages(c, images)
+		if err != nil {
+			return err
+		}
+		images = deduped
+	}
+
 	params := domain.PostAlbumParams{
 		Title:       req.Title,
 		Description: req.Description,
@@ -175,6 +220,7 @@ func (h *Handler) UpdateAlbum(c echo.Context) error {
 		Title       *string  `json:"title"`
 		Description *string  `json:"description"`
 		Images      []string `json:"images"`
+		Dedupe      bool     `json:"dedupe"`
 	})
 	if err := c.Bind(req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
@@ -210,6 +256,15 @@ func (h *Handler) UpdateAlbum(c echo.Context) error {
 		images = append(images, id)
 	}
 
+	// 見た目が同一の画像を除外（オプション）
+	if req.Dedupe {
+		deduped, err := h.dedupeRequestImages(c, images)
+		if err != nil {
+			return err
+		}
+		images = deduped
+	}
+
 	params := domain.UpdateAlbumParams{
 		Title:       req.Title,
 		Description: req.Description,
@@ -231,3 +286,27 @@ func (h *Handler) UpdateAlbum(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, updatedAlbum)
 }
+
+// dedupeRequestImages はリクエストのtraQトークンを使って images の重複を取り除く
+func (h *Handler) dedupeRequestImages(c echo.Context, images []uuid.UUID) ([]uuid.UUID, error) {
+	token := getSessionToken(c)
+	if token == "" {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	deduped, err := h.dedupeImages(c.Request().Context(), token, images)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to dedupe images").SetInternal(err)
+	}
+	return deduped, nil
+}
+
+// pickPlaceholders は placeholders のうち ids に含まれる画像のものだけを返す
+func pickPlaceholders(placeholders map[uuid.UUID]domain.ImagePlaceholder, ids []uuid.UUID) map[uuid.UUID]domain.ImagePlaceholder {
+	picked := make(map[uuid.UUID]domain.ImagePlaceholder, len(ids))
+	for _, id := range ids {
+		if p, ok := placeholders[id]; ok {
+			picked[id] = p
+		}
+	}
+	return picked
+}
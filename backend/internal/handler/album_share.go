@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+	"github.com/traP-jp/1m25_10/backend/pkg/config"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// アルバムを共有するメッセージに埋め込む画像の数の上限（多すぎるとメッセージが長くなりすぎる）
+	maxSharedAlbumImages = 10
+	// 共有時に添えるコメントの長さの上限（traQ のメッセージは10000文字まで）
+	maxShareCommentLength = 2000
+)
+
+// shareAlbumRequest は POST /api/v1/albums/:id/share/traq のリクエストボディ
+type shareAlbumRequest struct {
+	ChannelID string `json:"channel_id"`
+	Comment   string `json:"comment"`
+	// 埋め込む画像の数（省略時と上限は maxSharedAlbumImages）
+	MaxImages *int `json:"max_images"`
+}
+
+// shareAlbumResponse は投稿したメッセージ
+type shareAlbumResponse struct {
+	MessageID string `json:"message_id"`
+	ChannelID string `json:"channel_id"`
+	URL       string `json:"url"`
+}
+
+// ShareAlbumToTraq
+// POST /api/v1/albums/:id/share/traq
+// body: {"channel_id": "...", "comment": "...", "max_images": 4}
+// アルバムへのリンクと先頭の画像（最大 maxSharedAlbumImages 枚）を、ログイン中のユーザーとして traQ のチャンネルに投稿する。
+// 投稿には write スコープが必要。read のみを許可したユーザーには 403 write_scope_required と再認可のURLを返す。
+func (h *Handler) ShareAlbumToTraq(c echo.Context) error {
+	albumID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid album ID")
+	}
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	var req shareAlbumRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if _, err := uuid.Parse(req.ChannelID); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid channel_id")
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if len([]rune(req.Comment)) > maxShareCommentLength {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("comment must be at most %d characters", maxShareCommentLength))
+	}
+	maxImages := maxSharedAlbumImages
+	if req.MaxImages != nil {
+		if *req.MaxImages < 0 || *req.MaxImages > maxSharedAlbumImages {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("max_images must be between 0 and %d", maxSharedAlbumImages))
+		}
+		maxImages = *req.MaxImages
+	}
+
+	ctx := c.Request().Context()
+	album, err := h.repo.GetAlbum(ctx, albumID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Album not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve album").SetInternal(err)
+	}
+
+	albumPath := "/albums/" + album.Id.String()
+	content := h.albumShareContent(album, appBaseURL(c)+albumPath, req.Comment, maxImages)
+	m, err := h.traq.PostMessage(ctx, token, req.ChannelID, traq.PostMessageParams{Content: content, Embed: true})
+	if err != nil {
+		return traqWriteHTTPError(err, "failed to post message to traQ", albumPath)
+	}
+
+	return c.JSON(http.StatusCreated, shareAlbumResponse{
+		MessageID: m.ID,
+		ChannelID: m.ChannelID,
+		URL:       h.traq.MessageURL(m.ID),
+	})
+}
+
+// albumShareContent はアルバムを共有するメッセージの本文を作る
+func (h *Handler) albumShareContent(album *domain.Album, albumURL, comment string, maxImages int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "アルバム「%s」を共有しました (%d枚)\n", album.Title, len(album.Images))
+	if comment != "" {
+		b.WriteString(comment)
+		b.WriteByte('\n')
+	}
+	b.WriteString(albumURL)
+	for i, id := range album.Images {
+		if i >= maxImages {
+			break
+		}
+		b.WriteByte('\n')
+		b.WriteString(h.traq.FileURL(id.String()))
+	}
+	return b.String()
+}
+
+// appBaseURL はアプリ（フロントエンド）のベースURLを返す。設定が無ければリクエストのホストから推測する
+func appBaseURL(c echo.Context) string {
+	for _, base := range []string{config.FrontendBaseURL(), config.ServerBaseURL()} {
+		if base != "" {
+			return strings.TrimRight(base, "/")
+		}
+	}
+	return c.Scheme() + "://" + c.Request().Host
+}
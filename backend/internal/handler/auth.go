@@ -1,29 +1,30 @@
 package handler
 
 import (
-	"bytes"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
-	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/traP-jp/1m25_10/backend/pkg/config"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
 )
 
 const (
-	cookieTokenKey    = "traq-auth-token"
-	cookieStateKey    = "traq-auth-state"
-	cookieVerifierKey = "traq-auth-code-verifier"
-	cookieCallbackKey = "traq-auth-callback"
-	authBase          = "https://q.trap.jp/api/v3/oauth2"
+	// 以前 traQ のアクセストークンをそのまま置いていた Cookie。ログイン・ログアウト時に消す
+	legacyCookieTokenKey = "traq-auth-token"
+	cookieStateKey       = "traq-auth-state"
+	cookieVerifierKey    = "traq-auth-code-verifier"
+	cookieCallbackKey    = "traq-auth-callback"
+
+	// traQ に要求するスコープ。アルバムの共有（メッセージの投稿）に write が必要
+	oauthScope = "read write"
 )
 
 // GET /api/auth/request
@@ -56,16 +57,13 @@ func (h *Handler) AuthRequest(c echo.Context) error {
 	}
 
 	// 認可エンドポイントへリダイレクト
-	v := url.Values{}
-	v.Set("response_type", "code")
-	v.Set("client_id", clientID)
-	v.Set("redirect_uri", redirectURI)
-	v.Set("state", state)
-	v.Set("code_challenge", codeChallenge)
-	v.Set("code_challenge_method", "S256")
-	v.Set("scope", "read") // 必要なスコープを要求
-
-	authURL := authBase + "/authorize?" + v.Encode()
+	authURL := h.traq.AuthorizeURL(traq.AuthorizeParams{
+		ClientID:      clientID,
+		RedirectURI:   redirectURI,
+		State:         state,
+		CodeChallenge: codeChallenge,
+		Scope:         oauthScope,
+	})
 	return c.Redirect(http.StatusFound, authURL)
 }
 
@@ -87,13 +85,32 @@ func (h *Handler) AuthCallback(c echo.Context) error {
 	}
 
 	// トークン交換
-	token, expiresIn, err := exchangeToken(code, verifierCookie.Value)
+	token, err := h.traq.ExchangeToken(c.Request().Context(), traq.TokenParams{
+		ClientID:     config.TraqOAuthClientID(),
+		ClientSecret: config.TraqOAuthClientSecret(),
+		Code:         code,
+		CodeVerifier: verifierCookie.Value,
+		RedirectURI:  config.TraqOAuthRedirectURI(),
+	})
+	if err != nil {
+		return traqHTTPError(err, "token exchange failed")
+	}
+
+	// セッションをトークンの持ち主に結び付ける
+	me, err := h.traq.GetMe(c.Request().Context(), token.AccessToken)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadGateway, "token exchange failed").SetInternal(err)
+		return traqHTTPError(err, "failed to request traQ")
+	}
+	userID, err := uuid.Parse(me.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, "invalid user ID from traQ").SetInternal(err)
 	}
 
-	// アクセストークンをCookieに保存
-	setAuthCookie(c, cookieTokenKey, token, time.Duration(expiresIn)*time.Second)
+	// アクセストークンは暗号化してセッションに保存し、Cookieにはセッションの秘密の値だけを置く
+	if err := h.startSession(c, token.AccessToken, userID, time.Duration(token.ExpiresIn)*time.Second); err != nil {
+		return err
+	}
+	delCookie(c, legacyCookieTokenKey)
 
 	// 一時Cookieを削除
 	delCookie(c, cookieStateKey)
@@ -115,13 +132,16 @@ func (h *Handler) AuthCallback(c echo.Context) error {
 
 // GET /api/auth/me
 func (h *Handler) AuthMe(c echo.Context) error {
-	token := getTokenFromCookie(c)
+	token := getSessionToken(c)
 	if token == "" {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
 	// traQの /users/me を呼んでusernameを返す
-	u, err := fetchTraqMe(token)
+	u, err := h.traq.GetMe(c.Request().Context(), token)
 	if err != nil {
+		if traqUnavailable(err) {
+			return traqHTTPError(err, "failed to request traQ")
+		}
 		return echo.NewHTTPError(http.StatusUnauthorized).SetInternal(err)
 	}
 	return c.JSON(http.StatusOK, u)
@@ -129,7 +149,13 @@ func (h *Handler) AuthMe(c echo.Context) error {
 
 // POST /api/auth/logout
 func (h *Handler) AuthLogout(c echo.Context) error {
-	delCookie(c, cookieTokenKey)
+	if s := currentSession(c); s != nil {
+		if err := h.sessions.Delete(c.Request().Context(), s.Id); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete session").SetInternal(err)
+		}
+	}
+	delCookie(c, cookieSessionKey)
+	delCookie(c, legacyCookieTokenKey)
 	return c.NoContent(http.StatusNoContent)
 }
 
@@ -206,13 +232,6 @@ func delCookie(c echo.Context, name string) {
 	c.SetCookie(cookie)
 }
 
-func getTokenFromCookie(c echo.Context) string {
-	if ck, err := c.Cookie(cookieTokenKey); err == nil {
-		return ck.Value
-	}
-	return ""
-}
-
 // isValidRedirectPath validates that the callback is a safe, site-internal path.
 // Requirements:
 // - starts with a single '/'
@@ -249,79 +268,3 @@ func isValidRedirectPath(cb string) bool {
 	}
 	return true
 }
-
-// ------------- HTTP calls to traQ -------------
-type meResponse struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	DisplayName string `json:"displayName"`
-}
-
-func exchangeToken(code, codeVerifier string) (accessToken string, expiresIn int64, err error) {
-	form := url.Values{}
-	form.Set("grant_type", "authorization_code")
-	form.Set("client_id", config.TraqOAuthClientID())
-	if cs := config.TraqOAuthClientSecret(); cs != "" {
-		form.Set("client_secret", cs)
-	}
-	form.Set("code", code)
-	form.Set("code_verifier", codeVerifier)
-	if ru := config.TraqOAuthRedirectURI(); ru != "" {
-		form.Set("redirect_uri", ru)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, authBase+"/token", bytes.NewBufferString(form.Encode()))
-	if err != nil {
-		return "", 0, err
-	}
-	// x-www-form-urlencoded
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", 0, err
-	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			log.Printf("warn: failed to close response body: %v", cerr)
-		}
-	}()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		b, _ := io.ReadAll(resp.Body)
-		return "", 0, errors.New("token endpoint error (" + resp.Status + "): " + string(b))
-	}
-	// 最小限のparse（access_tokenとexpires_inのみ）
-	type tokenResp struct {
-		AccessToken string `json:"access_token"`
-		ExpiresIn   int64  `json:"expires_in"`
-		TokenType   string `json:"token_type"`
-	}
-	var tr tokenResp
-	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
-		return "", 0, err
-	}
-	return tr.AccessToken, tr.ExpiresIn, nil
-}
-
-func fetchTraqMe(token string) (*meResponse, error) {
-	req, _ := http.NewRequest(http.MethodGet, "https://q.trap.jp/api/v3/users/me", nil)
-	req.Header.Set("Authorization", "Bearer "+token)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			log.Printf("warn: failed to close response body: %v", cerr)
-		}
-	}()
-	if resp.StatusCode != http.StatusOK {
-		b, _ := io.ReadAll(resp.Body)
-		return nil, errors.New("me error: " + string(b))
-	}
-	var me meResponse
-	if err := json.NewDecoder(resp.Body).Decode(&me); err != nil {
-		return nil, err
-	}
-	return &me, nil
-}
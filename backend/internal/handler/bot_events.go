@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+	"github.com/traP-jp/1m25_10/backend/pkg/config"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// PostBotEvent
+// POST /api/bot/events
+// traQ BOT (HTTPモード) のイベントを受け取り、ローカルの画像索引とライブフィードを更新する。
+// リクエストは X-TRAQ-BOT-TOKEN の Verification Token で検証する。
+//   - MESSAGE_CREATED: 公開チャンネルの画像を含むメッセージを索引に追加し、ライブフィードに配信する
+//     （チャンネルの確認に TRAQ_SERVICE_TOKEN を使い、設定されていなければ索引しない）
+//   - BOT_MESSAGE_STAMPS_UPDATED: 索引済みのメッセージのスタンプを更新する（フィードの直近のメッセージなら配信し直す）
+//   - MESSAGE_DELETED: メッセージを索引から消す
+//
+// それ以外のイベントは受け取るだけで何もしない。
+func (h *Handler) PostBotEvent(c echo.Context) error {
+	verificationToken := config.TraqBotVerificationToken()
+	if verificationToken == "" {
+		return echo.NewHTTPError(http.StatusNotFound, "bot is not configured")
+	}
+	got := c.Request().Header.Get(traq.BotTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(verificationToken)) != 1 {
+		return echo.NewHTTPError(http.StatusForbidden, "invalid bot verification token")
+	}
+
+	ctx := c.Request().Context()
+	switch event := c.Request().Header.Get(traq.BotEventHeader); event {
+	case traq.BotEventMessageCreated:
+		var ev traq.MessageCreatedEvent
+		if err := c.Bind(&ev); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+		}
+		m, ok := h.indexedMessageFromEvent(ev.Message)
+		if !ok {
+			break
+		}
+		// BOT はプライベートチャンネルや DM のメッセージも受け取るため、クローラーと同じく公開チャンネルのものだけを索引する
+		token := config.TraqServiceToken()
+		if token == "" {
+			log.Printf("bot event ignored: TRAQ_SERVICE_TOKEN is not set")
+			break
+		}
+		public, err := h.isPublicChannel(ctx, token, m.ChannelID.String())
+		if err != nil {
+			return traqHTTPError(err, "failed to check channel")
+		}
+		if !public {
+			break
+		}
+		if err := h.repo.SaveIndexedMessages(ctx, []domain.IndexedMessage{m}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to index message").SetInternal(err)
+		}
+		h.feed.Publish(m)
+
+	case traq.BotEventBotMessageStampsUpdated:
+		var ev traq.BotMessageStampsUpdatedEvent
+		if err := c.Bind(&ev); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+		}
+		messageID, err := uuid.Parse(ev.MessageID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid message ID")
+		}
+		stamps := make([]domain.IndexedStamp, 0, len(ev.Stamps))
+		for _, s := range ev.Stamps {
+			stampID, err1 := uuid.Parse(s.StampID)
+			userID, err2 := uuid.Parse(s.UserID)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			stamps = append(stamps, domain.IndexedStamp{StampID: stampID, UserID: userID, Count: s.Count})
+		}
+		// 画像を含まない（索引していない）メッセージは無視する
+		if err := h.repo.UpdateIndexedMessageStamps(ctx, messageID, stamps); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update message stamps").SetInternal(err)
+		}
+		h.feed.UpdateStamps(messageID, stamps)
+
+	case traq.BotEventMessageDeleted:
+		var ev traq.MessageDeletedEvent
+		if err := c.Bind(&ev); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+		}
+		messageID, err := uuid.Parse(ev.Message.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid message ID")
+		}
+		if err := h.repo.DeleteIndexedMessage(ctx, messageID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete message from index").SetInternal(err)
+		}
+
+	case traq.BotEventPing:
+	default:
+		log.Printf("bot event ignored: %s", event)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// indexedMessageFromEvent はイベントのメッセージを索引の形に変換する。画像を含まなければ false を返す。
+func (h *Handler) indexedMessageFromEvent(m traq.BotEventMessage) (domain.IndexedMessage, bool) {
+	images := make([]uuid.UUID, 0)
+	for _, s := range h.extractUUIDsFromContent(m.Text) {
+		if id, err := uuid.Parse(s); err == nil {
+			images = append(images, id)
+		}
+	}
+	if len(images) == 0 {
+		return domain.IndexedMessage{}, false
+	}
+
+	id, err1 := uuid.Parse(m.ID)
+	channelID, err2 := uuid.Parse(m.ChannelID)
+	userID, err3 := uuid.Parse(m.User.ID)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return domain.IndexedMessage{}, false
+	}
+
+	return domain.IndexedMessage{
+		Id:        id,
+		ChannelID: channelID,
+		UserID:    userID,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+		Images:    images,
+		Stamps:    []domain.IndexedStamp{},
+	}, true
+}
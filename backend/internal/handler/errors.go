@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+// errorResponse はすべてのエンドポイントで共通のエラーレスポンス
+type errorResponse struct {
+	// 機械的に判別するための安定したエラーコード（例: "not_found", "traq_unavailable"）
+	Error     string      `json:"error"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id"`
+}
+
+// apiError はエラーコードと詳細を持つクライアント向けのエラー。HTTPErrorHandler が errorResponse に変換する
+type apiError struct {
+	status   int
+	code     string
+	message  string
+	details  interface{}
+	internal error
+}
+
+func newAPIError(status int, code, message string) *apiError {
+	return &apiError{status: status, code: code, message: message}
+}
+
+func (e *apiError) withDetails(details interface{}) *apiError {
+	e.details = details
+	return e
+}
+
+func (e *apiError) withInternal(err error) *apiError {
+	e.internal = err
+	return e
+}
+
+func (e *apiError) Error() string {
+	if e.internal != nil {
+		return fmt.Sprintf("code=%s, message=%s, internal=%v", e.code, e.message, e.internal)
+	}
+	return fmt.Sprintf("code=%s, message=%s", e.code, e.message)
+}
+
+func (e *apiError) Unwrap() error {
+	return e.internal
+}
+
+// HTTPErrorHandler is an echo.HTTPErrorHandler that writes every error as
+// {"error": code, "message": ..., "details": ..., "request_id": ...}.
+// Errors other than *apiError and *echo.HTTPError are hidden behind a 500 internal_server_error.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	var res errorResponse
+	status := http.StatusInternalServerError
+	var ae *apiError
+	var he *echo.HTTPError
+	switch {
+	case errors.As(err, &ae):
+		status = ae.status
+		res = errorResponse{Error: ae.code, Message: ae.message, Details: ae.details}
+	case errors.As(err, &he):
+		status = he.Code
+		msg, ok := he.Message.(string)
+		if !ok {
+			msg = fmt.Sprint(he.Message)
+		}
+		res = errorResponse{Error: errorCode(status), Message: msg}
+	case errors.Is(err, domain.ErrNotFound):
+		status = http.StatusNotFound
+		res = errorResponse{Error: errorCode(status), Message: "not found"}
+	default:
+		res = errorResponse{Error: errorCode(status), Message: "internal server error"}
+	}
+
+	res.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	if res.RequestID == "" {
+		res.RequestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+
+	var werr error
+	if c.Request().Method == http.MethodHead {
+		werr = c.NoContent(status)
+	} else {
+		werr = c.JSON(status, res)
+	}
+	if werr != nil {
+		c.Logger().Error(werr)
+	}
+}
+
+// errorCode はステータスコードから既定のエラーコードを作る（例: 404 → "not_found"）
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(strings.ReplaceAll(text, "-", " ")), " ", "_")
+}
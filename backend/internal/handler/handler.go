@@ -1,29 +1,87 @@
 package handler
 
 import (
-	"net/http"
+	"runtime"
+	"sync"
 
 	"github.com/traP-jp/1m25_10/backend/internal/handler/middleware"
 
+	"github.com/traP-jp/1m25_10/backend/internal/feed"
 	"github.com/traP-jp/1m25_10/backend/internal/repository"
+	"github.com/traP-jp/1m25_10/backend/internal/session"
+	"github.com/traP-jp/1m25_10/backend/pkg/cache"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/singleflight"
 )
 
 type Handler struct {
-	repo   repository.Repository
-	client *http.Client
-}
+	repo repository.Repository
+	traq *traq.Client
+	// ログインセッション（traQのアクセストークンを暗号化して保存する）
+	sessions *session.Store
 
-// New creates a Handler. If client is nil, http.DefaultClient will be used.
-func New(repo repository.Repository, client *http.Client) *Handler {
-	if client == nil {
-		client = http.DefaultClient
-	}
+	// リサイズ済み画像のキャッシュと、画像デコードの同時実行数を制限するセマフォ
+	variantCache *cache.LRU[string, imageVariant]
+	decodeSem    chan struct{}
+
+	// traQのファイル・サムネイルのディスクキャッシュ（nilなら無効）と、ユーザーごとのアクセス確認の記録
+	fileCache        *cache.Disk
+	fileAccess       *cache.TTL[string, struct{}]
+	fileCacheMetrics fileCacheMetrics
+
+	// traQのユーザー情報のキャッシュ（ID → 詳細、名前 → ID）と、同じユーザーへの同時リクエストをまとめるグループ
+	userCache     *cache.TTL[string, traq.UserDetail]
+	userIDsByName *cache.TTL[string, string]
+	userGroup     singleflight.Group
+
+	// traQの公開チャンネル一覧のキャッシュと、一覧の同時取得をまとめるグループ
+	channelCache *cache.TTL[string, *channelIndex]
+	channelGroup singleflight.Group
+
+	// traQのスタンプ一覧のキャッシュと、一覧の同時取得をまとめるグループ
+	stampCache *cache.TTL[string, *stampIndex]
+	stampGroup singleflight.Group
 
+	// 画像の投稿数の集計の、終わった区間ごとの件数のキャッシュ
+	histogramCache *cache.TTL[string, int]
+
+	// バックグラウンドで解析中の画像
+	analyzingMu sync.Mutex
+	analyzing   map[uuid.UUID]struct{}
+	// バックグラウンドでメタデータを抽出中の画像
+	extractingMu sync.Mutex
+	extracting   map[uuid.UUID]struct{}
+
+	// 新しく投稿された画像のライブフィード
+	feed *feed.Hub
+}
+
+// New creates a Handler that talks to traQ through traqClient.
+// Files proxied from traQ are cached in fileCache unless it is nil.
+// Messages received as bot events are published to hub, which also serves the live image feed.
+// The traQ access tokens of logged in users are kept in sessions.
+func New(repo repository.Repository, traqClient *traq.Client, fileCache *cache.Disk, hub *feed.Hub, sessions *session.Store) *Handler {
 	return &Handler{
-		repo:   repo,
-		client: client,
+		repo:       repo,
+		traq:       traqClient,
+		sessions:   sessions,
+		feed:       hub,
+		fileCache:  fileCache,
+		fileAccess: cache.NewTTL[string, struct{}](fileAccessMemoTTL, fileAccessMemoMaxEntries),
+		variantCache: cache.NewLRU[string](variantCacheMaxBytes, func(v imageVariant) int64 {
+			return int64(len(v.body))
+		}),
+		decodeSem:      make(chan struct{}, runtime.NumCPU()),
+		userCache:      cache.NewTTL[string, traq.UserDetail](userCacheTTL, userCacheMaxEntries),
+		userIDsByName:  cache.NewTTL[string, string](userCacheTTL, userCacheMaxEntries),
+		channelCache:   cache.NewTTL[string, *channelIndex](channelCacheTTL, 1),
+		stampCache:     cache.NewTTL[string, *stampIndex](stampCacheTTL, 1),
+		histogramCache: cache.NewTTL[string, int](histogramCacheTTL, histogramCacheMaxEntries),
+		analyzing:      make(map[uuid.UUID]struct{}),
+		extracting:     make(map[uuid.UUID]struct{}),
 	}
 }
 
@@ -39,6 +97,8 @@ func (h *Handler) SetupAppRoutes(api *echo.Group) {
 	{
 		albumAPI.GET("", h.GetAlbums)
 		albumAPI.GET("/:id", h.GetAlbum)
+		albumAPI.GET("/:id/duplicates", h.GetAlbumDuplicates)
+		albumAPI.POST("/:id/share/traq", h.ShareAlbumToTraq)
 		albumAPI.POST("", h.PostAlbum, middleware.UsernameProvider)
 		albumAPI.DELETE("/:id", h.DeleteAlbum, middleware.UsernameProvider)
 		// Prefer PATCH for partial updates; keep PUT for backward compatibility
@@ -50,7 +110,12 @@ func (h *Handler) SetupAppRoutes(api *echo.Group) {
 	imagesAPI := api.Group("/images")
 	{
 		imagesAPI.GET("", h.GetTraqMessagesSearchImages)
+		imagesAPI.GET("/stream", h.GetImageStream)
+		imagesAPI.GET("/histogram", h.GetImageHistogram)
 		imagesAPI.GET("/:id", h.GetLatestMessageByImageID)
+		imagesAPI.GET("/:id/messages", h.GetImageMessages)
+		imagesAPI.POST("/:id/stamps/:stampId", h.PostImageStamp)
+		imagesAPI.DELETE("/:id/stamps/:stampId", h.DeleteImageStamp)
 	}
 
 }
@@ -62,6 +127,13 @@ func (h *Handler) SetupAuthRoutes(authGroup *echo.Group) {
 	authGroup.GET("/callback", h.AuthCallback)
 	authGroup.GET("/me", h.AuthMe)
 	authGroup.POST("/logout", h.AuthLogout)
+	authGroup.GET("/sessions", h.GetSessions)
+	authGroup.DELETE("/sessions/:id", h.DeleteSession)
+}
+
+// SetupBotRoutes は `/api/bot` にマウントされる traQ BOT のイベント受信用ルートを登録します。
+func (h *Handler) SetupBotRoutes(botGroup *echo.Group) {
+	botGroup.POST("/events", h.PostBotEvent)
 }
 
 // SetupTraqRoutes は `/api/v1/traq` にマウントされる traQ プロキシ専用ルートを登録します。
@@ -69,6 +141,7 @@ func (h *Handler) SetupAuthRoutes(authGroup *echo.Group) {
 func (h *Handler) SetupTraqRoutes(traqGroup *echo.Group) {
 	filesGroup := traqGroup.Group("/files")
 	{
+		filesGroup.GET("/cache/stats", h.GetTraqFileCacheStats)
 		filesGroup.GET("/:uuid", h.GetTraqFile)
 		filesGroup.GET("/:uuid/thumbnail", h.GetTraqFileThumbnail)
 	}
@@ -76,4 +149,11 @@ func (h *Handler) SetupTraqRoutes(traqGroup *echo.Group) {
 	traqGroup.GET("/messages", h.GetTraqMessagesSearch)
 	// users endpoint proxy
 	traqGroup.GET("/users/:id", h.GetTraqUserByID)
+	traqGroup.POST("/users/batch", h.PostTraqUsersBatch)
+	// channels endpoints
+	traqGroup.GET("/channels", h.GetTraqChannels)
+	traqGroup.GET("/channels/resolve", h.GetTraqChannelByPath)
+	traqGroup.GET("/channels/:id", h.GetTraqChannelByID)
+	// stamps endpoint
+	traqGroup.GET("/stamps", h.GetTraqStamps)
 }
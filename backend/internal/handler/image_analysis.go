@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // register GIF decoder
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+	"github.com/traP-jp/1m25_10/backend/pkg/imaging"
+
+	"github.com/google/uuid"
+	_ "golang.org/x/image/webp" // register WebP decoder (traQ thumbnails)
+)
+
+const (
+	// サムネイル解析のバージョン。解析項目を増やしたら上げると既存の画像も再解析される
+	//   1: 知覚ハッシュ・blurhash・代表色
+	//   2: 主要色パレット
+	imageAnalysisVersion = 2
+	// 色検索用に保存する主要色の数
+	paletteSize = 5
+	// サムネイル取得の同時実行数
+	maxImageFetchConcurrency = 4
+	// バックグラウンド解析1回あたりの制限時間
+	backgroundAnalysisTimeout = time.Minute
+	// blurhashの成分数（横x縦）
+	blurhashXComponents = 4
+	blurhashYComponents = 3
+)
+
+// needsAnalysis はサムネイル由来の値が未計算、または古いバージョンで計算されたかどうかを返す
+func needsAnalysis(img domain.Image) bool {
+	return img.AnalysisVersion < imageAnalysisVersion
+}
+
+// imagePHashes は ids の知覚ハッシュを返す。
+// DBに保存済みのものはそれを使い、未計算のものはtraQのサムネイルから計算して保存する。
+// 取得・デコードに失敗した画像は結果に含めない。
+func (h *Handler) imagePHashes(ctx context.Context, token string, ids []uuid.UUID) (map[uuid.UUID]uint64, error) {
+	images, err := h.repo.GetImages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[uuid.UUID]uint64, len(ids))
+	for _, img := range images {
+		if img.PHash != nil {
+			hashes[img.Id] = *img.PHash
+		}
+	}
+
+	missing := make([]uuid.UUID, 0)
+	for _, id := range ids {
+		if _, ok := hashes[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	for id, a := range h.analyzeImages(ctx, token, missing) {
+		hashes[id] = a.PHash
+	}
+	return hashes, nil
+}
+
+// imagePlaceholders は ids のうち解析済みの画像のプレースホルダー情報を返す。
+// 未解析の画像があり token が得られている場合は、レスポンスを待たせないよう
+// バックグラウンドで解析を開始する（次回以降のレスポンスに含まれる）。
+func (h *Handler) imagePlaceholders(ctx context.Context, token string, ids []uuid.UUID) (map[uuid.UUID]domain.ImagePlaceholder, error) {
+	images, err := h.repo.GetImages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	placeholders := make(map[uuid.UUID]domain.ImagePlaceholder, len(images))
+	analyzed := make(map[uuid.UUID]struct{}, len(images))
+	for _, img := range images {
+		if img.Blurhash != nil && img.DominantColor != nil {
+			placeholders[img.Id] = domain.ImagePlaceholder{
+				Blurhash:      *img.Blurhash,
+				DominantColor: *img.DominantColor,
+			}
+		}
+		if !needsAnalysis(img) {
+			analyzed[img.Id] = struct{}{}
+		}
+	}
+
+	if token != "" {
+		missing := make([]uuid.UUID, 0)
+		for _, id := range ids {
+			if _, ok := analyzed[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+		h.analyzeImagesAsync(token, missing)
+	}
+
+	return placeholders, nil
+}
+
+// analyzeImagesAsync は analyzeImages をリクエストから切り離して実行する。
+// 既に解析中の画像は重複して解析しない。
+func (h *Handler) analyzeImagesAsync(token string, ids []uuid.UUID) {
+	h.analyzingMu.Lock()
+	targets := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := h.analyzing[id]; ok {
+			continue
+		}
+		h.analyzing[id] = struct{}{}
+		targets = append(targets, id)
+	}
+	h.analyzingMu.Unlock()
+
+	if len(targets) == 0 {
+		return
+	}
+
+	go func() {
+		defer func() {
+			h.analyzingMu.Lock()
+			for _, id := range targets {
+				delete(h.analyzing, id)
+			}
+			h.analyzingMu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundAnalysisTimeout)
+		defer cancel()
+		h.analyzeImages(ctx, token, targets)
+	}()
+}
+
+// analyzeImages はtraQのサムネイルを1度だけ取得し、知覚ハッシュ・blurhash・代表色を計算して保存する。
+// 取得・デコードに失敗した画像は結果に含めない。
+func (h *Handler) analyzeImages(ctx context.Context, token string, ids []uuid.UUID) map[uuid.UUID]domain.ImageAnalysis {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, maxImageFetchConcurrency)
+		results = make(map[uuid.UUID]domain.ImageAnalysis, len(ids))
+	)
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id uuid.UUID) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			img, err := h.fetchTraqThumbnailImage(ctx, token, id)
+			if err != nil {
+				log.Printf("warn: failed to fetch thumbnail (id=%s): %v", id, err)
+				return
+			}
+			a := analyzeImage(img)
+			if err := h.repo.SaveImageAnalysis(ctx, id, a); err != nil {
+				log.Printf("warn: %v", err)
+			}
+
+			mu.Lock()
+			results[id] = a
+			mu.Unlock()
+		}(id)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// analyzeImage はデコード済みのサムネイルから各種の値を計算する
+func analyzeImage(img image.Image) domain.ImageAnalysis {
+	palette := imaging.Palette(img, paletteSize)
+
+	// 代表色はパレットの先頭（最も多い色）
+	dominant := color.RGBA{A: 0xff}
+	if len(palette) > 0 {
+		dominant = palette[0].Color
+	}
+
+	a := domain.ImageAnalysis{
+		Version:       imageAnalysisVersion,
+		PHash:         imaging.DHash(img),
+		Blurhash:      imaging.Blurhash(img, blurhashXComponents, blurhashYComponents),
+		DominantColor: imaging.HexColor(dominant),
+		Palette:       make([]domain.PaletteColor, 0, len(palette)),
+	}
+	for _, pc := range palette {
+		lab := imaging.ToLab(pc.Color)
+		a.Palette = append(a.Palette, domain.PaletteColor{
+			Color:  domain.LabColor{L: lab.L, A: lab.A, B: lab.B},
+			Weight: pc.Weight,
+		})
+	}
+	return a
+}
+
+// fetchTraqThumbnailImage はtraQのサムネイルを取得してデコードする
+func (h *Handler) fetchTraqThumbnailImage(ctx context.Context, token string, id uuid.UUID) (image.Image, error) {
+	resp, err := h.traq.GetFileThumbnail(ctx, token, id.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			log.Printf("warn: failed to close response body: %v", cerr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("traQ thumbnail request failed: status=%d", resp.StatusCode)
+	}
+
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode thumbnail: %w", err)
+	}
+	return img, nil
+}
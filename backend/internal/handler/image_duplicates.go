@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+	"github.com/traP-jp/1m25_10/backend/pkg/imaging"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// 知覚ハッシュのハミング距離がこれ以下なら同一画像とみなす
+const defaultDuplicateThreshold = 10
+
+// GET /api/v1/albums/:id/duplicates
+// query: threshold (optional, 0-64)
+// アルバム内で見た目が同一の画像をグループ化して返す
+func (h *Handler) GetAlbumDuplicates(c echo.Context) error {
+	albumID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid album ID")
+	}
+
+	threshold := defaultDuplicateThreshold
+	if v := c.QueryParam("threshold"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 64 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid threshold")
+		}
+		threshold = n
+	}
+
+	// サムネイル取得にtraQトークンが必要
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	album, err := h.repo.GetAlbum(c.Request().Context(), albumID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Album not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve album").SetInternal(err)
+	}
+
+	hashes, err := h.imagePHashes(c.Request().Context(), token, album.Images)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute image hashes").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"groups": groupDuplicates(album.Images, hashes, threshold),
+	})
+}
+
+// dedupeImages は ids から、先に現れた画像と見た目が同一の画像を取り除く。
+// ハッシュを計算できなかった画像は残す。
+func (h *Handler) dedupeImages(ctx context.Context, token string, ids []uuid.UUID) ([]uuid.UUID, error) {
+	hashes, err := h.imagePHashes(ctx, token, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]uuid.UUID, 0, len(ids))
+	keptHashes := make([]uint64, 0, len(ids))
+	seen := make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		hash, ok := hashes[id]
+		if !ok {
+			kept = append(kept, id)
+			continue
+		}
+		dup := false
+		for _, k := range keptHashes {
+			if imaging.Distance(hash, k) <= defaultDuplicateThreshold {
+				dup = true
+				break
+			}
+		}
+		if dup {
+			continue
+		}
+		kept = append(kept, id)
+		keptHashes = append(keptHashes, hash)
+	}
+	return kept, nil
+}
+
+// groupDuplicates はハミング距離が threshold 以下の画像同士を同じグループにまとめる。
+// 2枚以上の画像を含むグループのみを ids の順序で返す。
+func groupDuplicates(ids []uuid.UUID, hashes map[uuid.UUID]uint64, threshold int) []domain.DuplicateGroup {
+	hashed := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := hashes[id]; ok {
+			hashed = append(hashed, id)
+		}
+	}
+
+	// union-find
+	parent := make([]int, len(hashed))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range hashed {
+		for j := i + 1; j < len(hashed); j++ {
+			if imaging.Distance(hashes[hashed[i]], hashes[hashed[j]]) <= threshold {
+				if ri, rj := find(i), find(j); ri != rj {
+					parent[rj] = ri
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]uuid.UUID)
+	roots := make([]int, 0)
+	for i, id := range hashed {
+		r := find(i)
+		if _, ok := members[r]; !ok {
+			roots = append(roots, r)
+		}
+		members[r] = append(members[r], id)
+	}
+
+	groups := make([]domain.DuplicateGroup, 0)
+	for _, r := range roots {
+		if len(members[r]) >= 2 {
+			groups = append(groups, domain.DuplicateGroup{Images: members[r]})
+		}
+	}
+	return groups
+}
@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 1回のリクエストで集計できる区間の数の上限（日ごとなら約1年分）
+	maxHistogramBuckets = 366
+	// 区間ごとの件数を traQ に問い合わせる同時実行数
+	maxHistogramConcurrency = 8
+	// 終わった区間の件数をキャッシュする期間（メッセージの作成日時は変わらないので、削除が反映されるまでの時間）
+	histogramCacheTTL        = 10 * time.Minute
+	histogramCacheMaxEntries = 50_000
+	// 省略時に集計する区間の数
+	defaultHistogramDays  = 30
+	defaultHistogramWeeks = 12
+)
+
+// histogramBucket は集計区間 [Start, End) の画像付きメッセージの数
+type histogramBucket struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Count int       `json:"count"`
+}
+
+type histogramResponse struct {
+	Bucket   string            `json:"bucket"`
+	Timezone string            `json:"timezone"`
+	Total    int               `json:"total"`
+	Buckets  []histogramBucket `json:"buckets"`
+}
+
+// GetImageHistogram
+// GET /api/v1/images/histogram
+// 画像付きメッセージの数を日ごと・週ごとに集計する（カレンダーやヒートマップ用）。
+// 区間ごとに traQ の検索 (after/before) の totalHits を並行して取得し、終わった区間の結果はキャッシュする。
+// query:
+//   - in: チャンネルUUID, channel: チャンネルのパス（例: gps/times）
+//   - from: 投稿者のユーザーUUID（複数指定可）
+//   - bucket: day（既定）| week（月曜始まり）
+//   - tz: 区間の区切りに使うタイムゾーン（IANA名、既定は Asia/Tokyo）
+//   - after: 集計の開始（RFC3339 または YYYY-MM-DD。区間の始まりに切り下げる。既定は直近30日/12週）
+//   - before: 集計の終わり（含まない。区間の区切りに切り上げる。既定は現在の区間の終わり）
+func (h *Handler) GetImageHistogram(c echo.Context) error {
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	ctx := c.Request().Context()
+
+	unit := c.QueryParam("bucket")
+	if unit == "" {
+		unit = "day"
+	}
+	if unit != "day" && unit != "week" {
+		return echo.NewHTTPError(http.StatusBadRequest, "bucket must be day or week")
+	}
+	loc := defaultLocation
+	if v := c.QueryParam("tz"); v != "" {
+		l, err := time.LoadLocation(v)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid tz")
+		}
+		loc = l
+	}
+
+	params := traq.MessageSearchParams{}
+	if v := c.QueryParam("in"); v != "" {
+		if _, err := uuid.Parse(v); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid in")
+		}
+		params.In = v
+	}
+	if path := c.QueryParam("channel"); path != "" {
+		_, id, err := h.resolveChannelPath(ctx, token, path)
+		if err != nil {
+			if errors.Is(err, errTraqChannelNotFound) {
+				return echo.NewHTTPError(http.StatusBadRequest, "unknown channel: "+path)
+			}
+			return traqHTTPError(err, "failed to resolve channel")
+		}
+		if params.In != "" && params.In != id {
+			return echo.NewHTTPError(http.StatusBadRequest, "in and channel specify different channels")
+		}
+		params.In = id
+	}
+	for _, v := range c.QueryParams()["from"] {
+		if _, err := uuid.Parse(v); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid from")
+		}
+		params.From = append(params.From, v)
+	}
+
+	now := time.Now().In(loc)
+	end := nextBucketStart(bucketStart(now, unit), unit)
+	if v := c.QueryParam("before"); v != "" {
+		t, err := parseHistogramTime(v, loc)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid before")
+		}
+		end = bucketStart(t, unit)
+		if end.Before(t) {
+			end = nextBucketStart(end, unit)
+		}
+	}
+	var start time.Time
+	if v := c.QueryParam("after"); v != "" {
+		t, err := parseHistogramTime(v, loc)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid after")
+		}
+		start = bucketStart(t, unit)
+	} else if unit == "week" {
+		start = end.AddDate(0, 0, -7*defaultHistogramWeeks)
+	} else {
+		start = end.AddDate(0, 0, -defaultHistogramDays)
+	}
+	if !start.Before(end) {
+		return echo.NewHTTPError(http.StatusBadRequest, "after must be before before")
+	}
+
+	var buckets []histogramBucket
+	for s := start; s.Before(end); s = nextBucketStart(s, unit) {
+		if len(buckets) == maxHistogramBuckets {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("too many buckets (max %d)", maxHistogramBuckets))
+		}
+		buckets = append(buckets, histogramBucket{Start: s, End: nextBucketStart(s, unit)})
+	}
+
+	if err := h.countHistogram(ctx, token, params, buckets, now); err != nil {
+		return traqHTTPError(err, "traQ search failed")
+	}
+
+	res := histogramResponse{Bucket: unit, Timezone: loc.String(), Buckets: buckets}
+	for _, b := range buckets {
+		res.Total += b.Count
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// countHistogram は各区間の画像付きメッセージの数を traQ の検索の totalHits で数える。
+// まだ始まっていない区間は 0 件とし、終わった区間の結果はキャッシュする。
+func (h *Handler) countHistogram(ctx context.Context, token string, params traq.MessageSearchParams, buckets []histogramBucket, now time.Time) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, maxHistogramConcurrency)
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := range buckets {
+		b := &buckets[i]
+		if b.Start.After(now) {
+			continue
+		}
+		key := histogramCacheKey(token, params, b.Start, b.End)
+		if n, ok := h.histogramCache.Get(key); ok {
+			b.Count = n
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			p := params
+			hasImage := true
+			limit := 1
+			p.HasImage = &hasImage
+			p.Limit = &limit
+			// traQ の after は指定した日時を含まないので、区間の始まりちょうどの投稿も数えるよう少し前にずらす
+			p.After = b.Start.Add(-time.Nanosecond).Format(time.RFC3339Nano)
+			p.Before = b.End.Format(time.RFC3339Nano)
+			res, err := h.traq.SearchMessages(ctx, token, &p)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			b.Count = res.TotalHits
+			if !b.End.After(now) {
+				h.histogramCache.Set(key, res.TotalHits)
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// histogramCacheKey は検索の条件と区間からキャッシュのキーを作る。
+// 見えるチャンネルはユーザーによって異なるので、トークンのハッシュを含める
+func histogramCacheKey(token string, p traq.MessageSearchParams, start, end time.Time) string {
+	sum := sha256.Sum256([]byte(token))
+	return strings.Join([]string{
+		hex.EncodeToString(sum[:16]),
+		p.In,
+		strings.Join(p.From, ","),
+		start.UTC().Format(time.RFC3339),
+		end.UTC().Format(time.RFC3339),
+	}, "|")
+}
+
+// bucketStart は t を含む区間の始まり（loc での0時。週は月曜日）を返す
+func bucketStart(t time.Time, unit string) time.Time {
+	y, m, d := t.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	if unit == "week" {
+		// 月曜始まり（Sunday=0 を 6 にする）
+		offset := (int(start.Weekday()) + 6) % 7
+		start = start.AddDate(0, 0, -offset)
+	}
+	return start
+}
+
+// nextBucketStart は start から始まる区間の次の区間の始まりを返す（夏時間の切り替えも考慮する）
+func nextBucketStart(start time.Time, unit string) time.Time {
+	if unit == "week" {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// parseHistogramTime は RFC3339 または loc での日付 (YYYY-MM-DD) を解釈する
+func parseHistogramTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(loc), nil
+}
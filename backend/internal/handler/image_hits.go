@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+)
+
+// imageHit は画像検索のヒット1件（hitFormat=rich）。
+// ID は画像のファイルUUIDで、その他は画像を含むメッセージの情報です。
+type imageHit struct {
+	ID         string          `json:"id"`
+	MessageID  string          `json:"messageId"`
+	UserID     string          `json:"userId"`
+	ChannelID  string          `json:"channelId"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	Stamps     []imageHitStamp `json:"stamps"`
+	StampCount int             `json:"stampCount"`
+}
+
+// imageHitStamp はメッセージに付いたスタンプの種類ごとの個数（全ユーザーの合計）
+type imageHitStamp struct {
+	StampID string `json:"stampId"`
+	Count   int    `json:"count"`
+}
+
+// imageHitsFromMessages はメッセージに含まれる画像をヒットに変換する。
+// 同じ画像が複数のメッセージに含まれる場合は、先に現れたメッセージのものだけを返す。
+func (h *Handler) imageHitsFromMessages(messages []traq.Message) []imageHit {
+	hits := make([]imageHit, 0)
+	seen := make(map[string]struct{})
+	for _, m := range messages {
+		fileIDs := h.extractUUIDsFromContent(m.Content)
+		if len(fileIDs) == 0 {
+			continue
+		}
+		stamps, total := aggregateStamps(m.Stamps)
+		for _, id := range fileIDs {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			hits = append(hits, imageHit{
+				ID:         id,
+				MessageID:  m.ID,
+				UserID:     m.UserID,
+				ChannelID:  m.ChannelID,
+				CreatedAt:  m.CreatedAt,
+				Stamps:     stamps,
+				StampCount: total,
+			})
+		}
+	}
+	return hits
+}
+
+// imageHitsFromIndexedMessage は索引の形のメッセージに含まれる画像をヒットに変換する
+func imageHitsFromIndexedMessage(m domain.IndexedMessage) []imageHit {
+	messageStamps := make([]traq.MessageStamp, 0, len(m.Stamps))
+	for _, s := range m.Stamps {
+		messageStamps = append(messageStamps, traq.MessageStamp{
+			StampID: s.StampID.String(),
+			UserID:  s.UserID.String(),
+			Count:   s.Count,
+		})
+	}
+	stamps, total := aggregateStamps(messageStamps)
+
+	hits := make([]imageHit, 0, len(m.Images))
+	seen := make(map[string]struct{})
+	for _, img := range m.Images {
+		id := img.String()
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		hits = append(hits, imageHit{
+			ID:         id,
+			MessageID:  m.Id.String(),
+			UserID:     m.UserID.String(),
+			ChannelID:  m.ChannelID.String(),
+			CreatedAt:  m.CreatedAt,
+			Stamps:     stamps,
+			StampCount: total,
+		})
+	}
+	return hits
+}
+
+// aggregateStamps はユーザーごとのスタンプを種類ごとにまとめ、合計の個数とともに返す（順序は stamps に現れた順）
+func aggregateStamps(stamps []traq.MessageStamp) ([]imageHitStamp, int) {
+	res := make([]imageHitStamp, 0)
+	index := make(map[string]int)
+	total := 0
+	for _, s := range stamps {
+		total += s.Count
+		if i, ok := index[s.StampID]; ok {
+			res[i].Count += s.Count
+			continue
+		}
+		index[s.StampID] = len(res)
+		res = append(res, imageHitStamp{StampID: s.StampID, Count: s.Count})
+	}
+	return res, total
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultImageMessagesLimit = 20
+	// traQ検索APIの limit の上限
+	maxImageMessagesLimit = 100
+	// 1メッセージあたりに返す引用メッセージの上限
+	maxQuotesPerMessage = 20
+)
+
+// imageMessage は画像を参照しているメッセージ1件です。
+// Kind は original(最初の投稿) / repost(同じ画像を含む別の投稿) / quote(それらの引用) のいずれかです。
+type imageMessage struct {
+	Kind      string              `json:"kind"`
+	ID        string              `json:"id"`
+	UserID    string              `json:"userId"`
+	ChannelID string              `json:"channelId"`
+	Content   string              `json:"content"`
+	CreatedAt time.Time           `json:"createdAt"`
+	UpdatedAt time.Time           `json:"updatedAt"`
+	Stamps    []traq.MessageStamp `json:"stamps"`
+	Quotes    *imageMessagePage   `json:"quotes,omitempty"`
+}
+
+type imageMessagePage struct {
+	TotalHits int            `json:"totalHits"`
+	Hits      []imageMessage `json:"hits"`
+}
+
+func newImageMessage(kind string, m traq.Message) imageMessage {
+	stamps := m.Stamps
+	if stamps == nil {
+		stamps = []traq.MessageStamp{}
+	}
+	return imageMessage{
+		Kind:      kind,
+		ID:        m.ID,
+		UserID:    m.UserID,
+		ChannelID: m.ChannelID,
+		Content:   m.Content,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+		Stamps:    stamps,
+	}
+}
+
+// GetImageMessages
+// GET /api/v1/images/:id/messages
+// query: limit (1-100, default 20), offset
+// 画像を含むすべてのメッセージ（最初の投稿と再投稿）を古い順に返し、各メッセージにはその引用メッセージを付与します。
+func (h *Handler) GetImageMessages(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid image ID")
+	}
+
+	limit := defaultImageMessagesLimit
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		limit = min(n, maxImageMessagesLimit)
+	}
+	offset := 0
+	if v := c.QueryParam("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid offset")
+		}
+		offset = n
+	}
+
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	ctx := c.Request().Context()
+
+	// 画像URLを含むメッセージを古い順に取得
+	res, err := h.traq.SearchMessages(ctx, token, &traq.MessageSearchParams{
+		Word:   h.traq.FileURL(id.String()),
+		Limit:  &limit,
+		Offset: &offset,
+		Sort:   "createdAt",
+	})
+	if err != nil {
+		return traqHTTPError(err, "failed to search traQ messages")
+	}
+
+	hits := make([]imageMessage, len(res.Hits))
+	for i, m := range res.Hits {
+		kind := "repost"
+		if offset == 0 && i == 0 {
+			kind = "original"
+		}
+		hits[i] = newImageMessage(kind, m)
+	}
+
+	// 各メッセージの引用を並行して取得
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, maxImageFetchConcurrency)
+		errMu    sync.Mutex
+		firstErr error
+	)
+	for i := range hits {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			quotes, err := h.fetchQuotes(ctx, token, hits[i].ID)
+			if err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+				return
+			}
+			hits[i].Quotes = quotes
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return traqHTTPError(firstErr, "failed to search traQ quotes")
+	}
+
+	return c.JSON(http.StatusOK, imageMessagePage{
+		TotalHits: res.TotalHits,
+		Hits:      hits,
+	})
+}
+
+// fetchQuotes は messageID を引用しているメッセージを古い順に取得する
+func (h *Handler) fetchQuotes(ctx context.Context, token, messageID string) (*imageMessagePage, error) {
+	limit := maxQuotesPerMessage
+	res, err := h.traq.SearchMessages(ctx, token, &traq.MessageSearchParams{
+		Citation: messageID,
+		Limit:    &limit,
+		Sort:     "createdAt",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &imageMessagePage{
+		TotalHits: res.TotalHits,
+		Hits:      make([]imageMessage, 0, len(res.Hits)),
+	}
+	for _, m := range res.Hits {
+		page.Hits = append(page.Hits, newImageMessage("quote", m))
+	}
+	return page, nil
+}
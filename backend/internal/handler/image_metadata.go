@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+	"github.com/traP-jp/1m25_10/backend/pkg/imaging"
+
+	"github.com/google/uuid"
+)
+
+// defaultLocation はタイムゾーン情報を持たない日時(EXIFのDateTimeOriginalなど)の解釈に使う
+var defaultLocation = loadLocation("Asia/Tokyo")
+
+func loadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		// tzdataが無い環境向けのフォールバック
+		return time.FixedZone("JST", 9*60*60)
+	}
+	return loc
+}
+
+// バックグラウンドでのメタデータ抽出1回あたりの制限時間（元画像を取得するので解析より長くする）
+const backgroundMetadataTimeout = 2 * time.Minute
+
+// getImageMetadata は ids の画像情報を返す。
+// メタデータ未抽出の画像があれば、ログイン済みならバックグラウンドで抽出を始める（結果は次回以降のリクエストに反映される）。
+func (h *Handler) getImageMetadata(ctx context.Context, token string, ids []uuid.UUID) (map[uuid.UUID]domain.Image, error) {
+	images, err := h.repo.GetImages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]domain.Image, len(images))
+	for _, img := range images {
+		byID[img.Id] = img
+	}
+
+	if token != "" {
+		missing := make([]uuid.UUID, 0)
+		for _, id := range ids {
+			if img, ok := byID[id]; !ok || img.MetadataExtractedAt == nil {
+				missing = append(missing, id)
+			}
+		}
+		h.extractImagesMetadataAsync(token, missing)
+	}
+
+	return byID, nil
+}
+
+// extractImagesMetadataAsync は extractImagesMetadata をリクエストから切り離して実行する。
+// 既に抽出中の画像は重複して抽出しない。
+func (h *Handler) extractImagesMetadataAsync(token string, ids []uuid.UUID) {
+	h.extractingMu.Lock()
+	targets := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := h.extracting[id]; ok {
+			continue
+		}
+		h.extracting[id] = struct{}{}
+		targets = append(targets, id)
+	}
+	h.extractingMu.Unlock()
+
+	if len(targets) == 0 {
+		return
+	}
+
+	go func() {
+		defer func() {
+			h.extractingMu.Lock()
+			for _, id := range targets {
+				delete(h.extracting, id)
+			}
+			h.extractingMu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundMetadataTimeout)
+		defer cancel()
+		h.extractImagesMetadata(ctx, token, targets)
+	}()
+}
+
+// extractImagesMetadata は traQ から元画像と投稿メッセージを取得してEXIFと投稿日時を保存する。
+// 取得に失敗した画像は次の機会に抽出し直す。
+func (h *Handler) extractImagesMetadata(ctx context.Context, token string, ids []uuid.UUID) {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, maxImageFetchConcurrency)
+	)
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id uuid.UUID) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			md, err := h.extractImageMetadata(ctx, token, id)
+			if err != nil {
+				log.Printf("warn: failed to extract image metadata (id=%s): %v", id, err)
+				return
+			}
+			if err := h.repo.SaveImageMetadata(ctx, id, md); err != nil {
+				log.Printf("warn: %v", err)
+			}
+		}(id)
+	}
+	wg.Wait()
+}
+
+// extractImageMetadata は元画像のEXIFと、画像を含む最古のメッセージの投稿日時を取得する。
+// EXIFが無い・JPEGでない場合は撮影情報を空のまま返す。
+func (h *Handler) extractImageMetadata(ctx context.Context, token string, id uuid.UUID) (domain.ImageMetadata, error) {
+	var md domain.ImageMetadata
+
+	ex, err := h.fetchTraqFileExif(ctx, token, id)
+	if err != nil && !errors.Is(err, imaging.ErrNoExif) {
+		return md, err
+	}
+	if ex != nil {
+		md.TakenAt = ex.TakenAt
+		if ex.CameraModel != "" {
+			md.CameraModel = &ex.CameraModel
+		}
+		if ex.Orientation != 0 {
+			md.Orientation = &ex.Orientation
+		}
+	}
+
+	postedAt, err := h.fetchImagePostedAt(ctx, token, id)
+	if err != nil {
+		return md, err
+	}
+	md.PostedAt = postedAt
+
+	return md, nil
+}
+
+// fetchTraqFileExif はtraQの元画像を取得し、先頭のEXIFセグメントのみを読み取る
+func (h *Handler) fetchTraqFileExif(ctx context.Context, token string, id uuid.UUID) (*imaging.Exif, error) {
+	resp, err := h.traq.GetFile(ctx, token, id.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			log.Printf("warn: failed to close response body: %v", cerr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("traQ file request failed: status=%d", resp.StatusCode)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/jpeg") {
+		return nil, imaging.ErrNoExif
+	}
+
+	return imaging.ParseJPEGExif(resp.Body, defaultLocation)
+}
+
+// fetchImagePostedAt は画像を含む最古のメッセージの投稿日時を返す。見つからなければ nil。
+func (h *Handler) fetchImagePostedAt(ctx context.Context, token string, id uuid.UUID) (*time.Time, error) {
+	m, err := h.findOldestImageMessage(ctx, token, id.String())
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return &m.CreatedAt, nil
+}
+
+// sortImagesByTakenAt は撮影日時(無ければ投稿日時)の昇順に ids を並べ替える。
+// どちらも不明な画像（メタデータを抽出中で、画像の索引にも無いもの）は末尾に元の順序で並べる。
+func sortImagesByTakenAt(ids []uuid.UUID, images map[uuid.UUID]domain.Image) {
+	key := func(id uuid.UUID) *time.Time {
+		img, ok := images[id]
+		if !ok {
+			return nil
+		}
+		if img.TakenAt != nil {
+			return img.TakenAt
+		}
+		return img.PostedAt
+	}
+
+	sort.SliceStable(ids, func(i, j int) bool {
+		ti, tj := key(ids[i]), key(ids[j])
+		if ti == nil || tj == nil {
+			return ti != nil && tj == nil
+		}
+		return ti.Before(*tj)
+	})
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+	"github.com/traP-jp/1m25_10/backend/pkg/imaging"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 色検索の許容色差(CIE76)の既定値
+	defaultColorTolerance = 20.0
+	maxColorTolerance     = 100.0
+	defaultLocalLimit     = 20
+	maxLocalLimit         = 100
+)
+
+// searchLocalImages は GET /api/v1/images?source=local の実装。
+// traQには問い合わせず、アプリが既に把握している画像（アルバムに追加された画像や、クローラーが索引した画像）から検索する。
+// query: color (#rrggbb), tolerance (0-100), limit, offset
+// 以下は索引したメッセージに対する条件:
+// in (チャンネルUUID) または channel (チャンネルのパス), from (ユーザーUUID、複数指定可), after, before (RFC3339),
+// stampId, stamp, stampMode, minStampCount (traQ検索と同じ)
+func (h *Handler) searchLocalImages(c echo.Context) error {
+	// 索引したメッセージの情報を含むため、traQ検索と同じくログインを必須とする
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	filter := domain.ImageSearchFilter{
+		Tolerance: defaultColorTolerance,
+		Limit:     defaultLocalLimit,
+	}
+
+	if v := c.QueryParam("color"); v != "" {
+		rgb, err := imaging.ParseHexColor(v)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid color")
+		}
+		lab := imaging.ToLab(rgb)
+		filter.Color = &domain.LabColor{L: lab.L, A: lab.A, B: lab.B}
+	}
+	if v := c.QueryParam("tolerance"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 || t > maxColorTolerance {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid tolerance")
+		}
+		filter.Tolerance = t
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		filter.Limit = min(n, maxLocalLimit)
+	}
+	if v := c.QueryParam("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid offset")
+		}
+		filter.Offset = n
+	}
+
+	if err := h.applyLocalMessageFilter(c, &filter); err != nil {
+		return err
+	}
+
+	total, ids, err := h.repo.SearchImages(c.Request().Context(), filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to search images").SetInternal(err)
+	}
+
+	placeholders, err := h.imagePlaceholders(c.Request().Context(), token, ids)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image placeholders").SetInternal(err)
+	}
+
+	// traQ検索と同じ形で返す
+	hits := make([]string, 0, len(ids))
+	for _, id := range ids {
+		hits = append(hits, id.String())
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"totalHits":    total,
+		"hits":         hits,
+		"placeholders": placeholders,
+	})
+}
+
+// applyLocalMessageFilter はクエリのメッセージに対する条件を filter に設定する
+func (h *Handler) applyLocalMessageFilter(c echo.Context, filter *domain.ImageSearchFilter) error {
+	parseUUIDs := func(name string, values []string) ([]uuid.UUID, error) {
+		ids := make([]uuid.UUID, 0, len(values))
+		for _, v := range values {
+			if v == "" {
+				continue
+			}
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid "+name)
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+
+	if v := c.QueryParam("in"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid in")
+		}
+		filter.ChannelID = &id
+	}
+	if path := c.QueryParam("channel"); path != "" {
+		token := getSessionToken(c)
+		if token == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+		}
+		_, s, err := h.resolveChannelPath(c.Request().Context(), token, path)
+		if err != nil {
+			if errors.Is(err, errTraqChannelNotFound) {
+				return echo.NewHTTPError(http.StatusBadRequest, "unknown channel: "+path)
+			}
+			return traqHTTPError(err, "failed to resolve channel")
+		}
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadGateway, "invalid channel ID from traQ").SetInternal(err)
+		}
+		if filter.ChannelID != nil && *filter.ChannelID != id {
+			return echo.NewHTTPError(http.StatusBadRequest, "in and channel specify different channels")
+		}
+		filter.ChannelID = &id
+	}
+
+	userIDs, err := parseUUIDs("from", c.QueryParams()["from"])
+	if err != nil {
+		return err
+	}
+	filter.UserIDs = userIDs
+
+	for name, dst := range map[string]**time.Time{"after": &filter.After, "before": &filter.Before} {
+		if v := c.QueryParam(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+name)
+			}
+			*dst = &t
+		}
+	}
+
+	sf, err := h.stampFilterFromQuery(c)
+	if err != nil {
+		return err
+	}
+	if sf != nil {
+		stampIDs, err := parseUUIDs("stampId", sf.stampIDs)
+		if err != nil {
+			return err
+		}
+		filter.StampIDs = stampIDs
+		filter.StampMatchAll = sf.matchAll
+		filter.MinStampCount = sf.minCount
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// PostImageStamp
+// POST /api/v1/images/:id/stamps/:stampId
+// 画像を最初に投稿したメッセージ（GET /api/v1/images/:id と同じ）に、ログイン中のユーザーとしてスタンプを付ける。
+// 投稿には write スコープが必要。read のみを許可したユーザーには 403 write_scope_required を返す。
+func (h *Handler) PostImageStamp(c echo.Context) error {
+	return h.updateImageStamp(c, true)
+}
+
+// DeleteImageStamp
+// DELETE /api/v1/images/:id/stamps/:stampId
+// 画像を最初に投稿したメッセージから、ログイン中のユーザーが付けたスタンプを外す。
+func (h *Handler) DeleteImageStamp(c echo.Context) error {
+	return h.updateImageStamp(c, false)
+}
+
+func (h *Handler) updateImageStamp(c echo.Context, add bool) error {
+	imageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid image ID")
+	}
+	stampID, err := uuid.Parse(c.Param("stampId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid stamp ID")
+	}
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	ctx := c.Request().Context()
+
+	m, err := h.findOldestImageMessage(ctx, token, imageID.String())
+	if err != nil {
+		return traqHTTPError(err, "traQ search failed")
+	}
+	if m == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "no message found for the given image id")
+	}
+
+	if add {
+		err = h.traq.AddMessageStamp(ctx, token, m.ID, stampID.String(), 0)
+	} else {
+		err = h.traq.RemoveMessageStamp(ctx, token, m.ID, stampID.String())
+	}
+	if err != nil {
+		return traqWriteHTTPError(err, "failed to update stamps on traQ", "")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+	"github.com/traP-jp/1m25_10/backend/internal/feed"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 接続が途切れていないことを伝えるコメントを送る間隔（プロキシにアイドルとみなされないように）
+	streamHeartbeatInterval = 15 * time.Second
+	// 切断されたクライアントが再接続するまでの待ち時間
+	streamRetry = 3 * time.Second
+	// 接続ごとに送信済みとして覚えておくメッセージの数の上限
+	maxStreamSentMessages = 10000
+)
+
+// GetImageStream
+// GET /api/v1/images/stream
+// 新しく投稿された画像を Server-Sent Events で配信する（公開チャンネルの投稿のみ）。
+// 画像ごとに event: image で、data は hitFormat=rich のヒット1件（同じメッセージの画像は同じ id）。
+// query: in, channel, from, after, before, stampId, stamp, stampMode, minStampCount（source=local の検索と同じ）
+// スタンプの条件は、後からスタンプが付いて条件を満たしたときにも配信する。
+// 再接続時は Last-Event-ID ヘッダー（または lastEventId クエリ）以降の直近のイベントを送り直す。
+func (h *Handler) GetImageStream(c echo.Context) error {
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	ctx := c.Request().Context()
+
+	var filter domain.ImageSearchFilter
+	if err := h.applyLocalMessageFilter(c, &filter); err != nil {
+		return err
+	}
+
+	var lastEventID uint64
+	v := c.Request().Header.Get("Last-Event-ID")
+	if v == "" {
+		v = c.QueryParam("lastEventId")
+	}
+	if v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid Last-Event-ID")
+		}
+		lastEventID = id
+	}
+
+	// 配信を始める前に公開チャンネルの一覧を取得しておく（traQに接続できなければエラーを返せるように）
+	if _, err := h.getChannelIndex(ctx, token, false); err != nil {
+		return traqHTTPError(err, "failed to request traQ")
+	}
+
+	sub, backlog := h.feed.Subscribe(lastEventID)
+	defer h.feed.Unsubscribe(sub)
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	// nginx などにバッファリングさせない
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(res, "retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+		return nil
+	}
+	res.Flush()
+
+	// 送信済みのメッセージ（スタンプの更新で条件を満たしたときだけ送るため）
+	sent := make(map[uuid.UUID]struct{})
+	send := func(e feed.Event) error {
+		m := e.Message
+		if _, ok := sent[m.Id]; ok || !filter.MatchMessage(m) {
+			return nil
+		}
+		public, err := h.isPublicChannel(ctx, token, m.ChannelID.String())
+		if err != nil {
+			log.Printf("warn: image stream: failed to check channel %s: %v", m.ChannelID, err)
+			return nil
+		}
+		if !public {
+			return nil
+		}
+
+		if len(sent) >= maxStreamSentMessages {
+			clear(sent)
+		}
+		sent[m.Id] = struct{}{}
+		for _, hit := range imageHitsFromIndexedMessage(m) {
+			b, err := json.Marshal(hit)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(res, "id: %d\nevent: image\ndata: %s\n\n", e.ID, b); err != nil {
+				return err
+			}
+		}
+		res.Flush()
+		return nil
+	}
+
+	// ヘッダーを送った後はエラーを返せないので、書き込みに失敗したら（クライアントが切断したら）終了する
+	for _, e := range backlog {
+		if err := send(e); err != nil {
+			return nil
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				// 配信に追いつけずに切断された。クライアントは Last-Event-ID で再開できる
+				return nil
+			}
+			if err := send(e); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
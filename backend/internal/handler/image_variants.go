@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/imaging"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// リサイズ後のバリアントを保持するメモリキャッシュの上限
+	variantCacheMaxBytes = 64 << 20
+	// リサイズ対象として読み込む元画像の上限
+	maxVariantSourceBytes = 32 << 20
+	// デコードを許可する最大ピクセル数（デコード時のメモリ消費を抑える）
+	maxVariantSourcePixels = 50_000_000
+	variantJPEGQuality     = 85
+)
+
+// allowedVariantSizes はリサイズ可能な辺の長さ。指定値はこのいずれかに切り上げる。
+var allowedVariantSizes = []int{160, 320, 640, 1280, 1920}
+
+type imageVariant struct {
+	contentType string
+	body        []byte
+}
+
+type variantParams struct {
+	w, h int
+	fit  string
+}
+
+// parseVariantParams は w, h, fit クエリを解釈する。いずれも未指定なら ok=false。
+func parseVariantParams(c echo.Context) (p variantParams, ok bool, err error) {
+	ws, hs, fit := c.QueryParam("w"), c.QueryParam("h"), c.QueryParam("fit")
+	if ws == "" && hs == "" && fit == "" {
+		return p, false, nil
+	}
+
+	if ws != "" {
+		if p.w, err = parseVariantSize(ws); err != nil {
+			return p, false, echo.NewHTTPError(http.StatusBadRequest, "Invalid w")
+		}
+	}
+	if hs != "" {
+		if p.h, err = parseVariantSize(hs); err != nil {
+			return p, false, echo.NewHTTPError(http.StatusBadRequest, "Invalid h")
+		}
+	}
+	if p.w == 0 && p.h == 0 {
+		return p, false, echo.NewHTTPError(http.StatusBadRequest, "w or h is required")
+	}
+
+	switch fit {
+	case "", imaging.FitContain:
+		p.fit = imaging.FitContain
+	case imaging.FitCover:
+		p.fit = imaging.FitCover
+	default:
+		return p, false, echo.NewHTTPError(http.StatusBadRequest, "Invalid fit")
+	}
+	return p, true, nil
+}
+
+// parseVariantSize は許可されたサイズのうち指定値以上で最小のものを返す
+func parseVariantSize(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	for _, size := range allowedVariantSizes {
+		if n <= size {
+			return size, nil
+		}
+	}
+	return allowedVariantSizes[len(allowedVariantSizes)-1], nil
+}
+
+func (p variantParams) cacheKey(fileID string) string {
+	return fmt.Sprintf("%s:%dx%d:%s", fileID, p.w, p.h, p.fit)
+}
+
+// getTraqFileVariant はtraQの元画像をサーバー側でリサイズして返す
+func (h *Handler) getTraqFileVariant(c echo.Context, fileID, token string, p variantParams) error {
+	// キャッシュから返す場合も、ユーザーがファイルにアクセスできることをtraQで確認する
+	if _, err := h.traq.GetFileMeta(c.Request().Context(), token, fileID); err != nil {
+		return traqHTTPError(err, "failed to fetch file meta from traQ")
+	}
+
+	key := p.cacheKey(fileID)
+	if v, ok := h.variantCache.Get(key); ok {
+		return writeImageVariant(c, v)
+	}
+
+	resp, err := h.traq.GetFile(c.Request().Context(), token, fileID, nil)
+	if err != nil {
+		return traqHTTPError(err, "failed to fetch file from traQ")
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			log.Printf("warn: failed to close response body: %v", cerr)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return h.proxyResponse(c, resp)
+	}
+
+	src, err := io.ReadAll(io.LimitReader(resp.Body, maxVariantSourceBytes+1))
+	if err != nil {
+		return traqHTTPError(err, "failed to read file from traQ")
+	}
+	if len(src) > maxVariantSourceBytes {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "file is too large to resize")
+	}
+
+	v, err := h.resizeImage(c.Request().Context(), src, p)
+	if err != nil {
+		return err
+	}
+	h.variantCache.Add(key, v)
+
+	return writeImageVariant(c, v)
+}
+
+// resizeImage は src をデコード・リサイズ・再エンコードする。
+// デコードはメモリとCPUを大きく消費するため同時実行数を制限する。
+func (h *Handler) resizeImage(ctx context.Context, src []byte, p variantParams) (imageVariant, error) {
+	select {
+	case h.decodeSem <- struct{}{}:
+		defer func() { <-h.decodeSem }()
+	case <-ctx.Done():
+		return imageVariant{}, ctx.Err()
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return imageVariant{}, echo.NewHTTPError(http.StatusUnsupportedMediaType, "unsupported image format").SetInternal(err)
+	}
+	if cfg.Width*cfg.Height > maxVariantSourcePixels {
+		return imageVariant{}, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "image is too large to resize")
+	}
+
+	// GIFは先頭フレームのみをデコードする
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return imageVariant{}, echo.NewHTTPError(http.StatusUnsupportedMediaType, "failed to decode image").SetInternal(err)
+	}
+
+	resized := imaging.Resize(img, p.w, p.h, p.fit)
+
+	var buf bytes.Buffer
+	v := imageVariant{}
+	switch format {
+	case "png", "gif":
+		// 透過を保つためPNGで再エンコード
+		v.contentType = "image/png"
+		err = png.Encode(&buf, resized)
+	default:
+		v.contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: variantJPEGQuality})
+	}
+	if err != nil {
+		return imageVariant{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to encode image").SetInternal(err)
+	}
+	v.body = buf.Bytes()
+	return v, nil
+}
+
+func writeImageVariant(c echo.Context, v imageVariant) error {
+	// ユーザーごとに認可が必要なため共有キャッシュには載せない
+	c.Response().Header().Set("Cache-Control", "private, max-age=86400")
+	return c.Blob(http.StatusOK, v.contentType, v.body)
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"github.com/labstack/echo/v4"
+)
+
+// searchCursor は検索の続きを取得するためのカーソルの中身。
+// クライアントには中身に依存させないよう、エンコードした文字列として渡す。
+type searchCursor struct {
+	// 次に調べる traQ 検索結果の offset
+	Offset int `json:"o"`
+}
+
+func encodeSearchCursor(offset int) string {
+	b, _ := json.Marshal(searchCursor{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSearchCursor(s string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	var cur searchCursor
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return 0, err
+	}
+	if cur.Offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	return cur.Offset, nil
+}
+
+// applySearchCursor はクエリの cursor を検索の offset に反映する（offset より優先する）
+func applySearchCursor(c echo.Context, p *traq.MessageSearchParams) error {
+	v := c.QueryParam("cursor")
+	if v == "" {
+		return nil
+	}
+	offset, err := decodeSearchCursor(v)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid cursor").SetInternal(err)
+	}
+	p.Offset = &offset
+	return nil
+}
+
+// nextSearchCursor は続きがあればそのカーソルを、無ければ nil を返す（JSON では null になる）
+func nextSearchCursor(nextOffset int) *string {
+	if nextOffset < 0 {
+		return nil
+	}
+	cur := encodeSearchCursor(nextOffset)
+	return &cur
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+	"github.com/traP-jp/1m25_10/backend/internal/session"
+	"github.com/traP-jp/1m25_10/backend/pkg/config"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// セッションの秘密の値を置く Cookie
+	cookieSessionKey = "traq-auth-session"
+
+	// リクエストのセッションと traQ のアクセストークンを echo.Context に置くキー
+	sessionContextKey      = "session"
+	sessionTokenContextKey = "session_token"
+)
+
+// sessionResponse はログイン中のセッション1件
+type sessionResponse struct {
+	domain.Session
+	// このリクエストのセッションか
+	Current bool `json:"current"`
+}
+
+// SessionProvider は Cookie のセッションを読み込み、traQ のアクセストークンをハンドラーから使えるようにするミドルウェア。
+// セッションが無い・期限切れのときは未ログインとして続ける（各ハンドラーが 401 を返す）。
+func (h *Handler) SessionProvider(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ck, err := c.Cookie(cookieSessionKey)
+		if err != nil || ck.Value == "" {
+			return next(c)
+		}
+		s, token, err := h.sessions.Lookup(c.Request().Context(), ck.Value)
+		if err != nil {
+			if errors.Is(err, session.ErrNotFound) {
+				// 失効したセッションの Cookie は消しておく
+				delCookie(c, cookieSessionKey)
+				return next(c)
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to load session").SetInternal(err)
+		}
+		c.Set(sessionContextKey, s)
+		c.Set(sessionTokenContextKey, token)
+		return next(c)
+	}
+}
+
+// getSessionToken はリクエストのセッションの traQ アクセストークンを返す。ログインしていなければ空文字列
+func getSessionToken(c echo.Context) string {
+	token, _ := c.Get(sessionTokenContextKey).(string)
+	return token
+}
+
+// currentSession はリクエストのセッションを返す。ログインしていなければ nil
+func currentSession(c echo.Context) *domain.Session {
+	s, _ := c.Get(sessionContextKey).(*domain.Session)
+	return s
+}
+
+// startSession は traQ のアクセストークンでセッションを始め、Cookie を設定する。
+// リクエストに既にセッションがあれば終了する（ログインし直したときに古いセッションを残さない）。
+func (h *Handler) startSession(c echo.Context, token string, userID uuid.UUID, tokenExpiresIn time.Duration) error {
+	ctx := c.Request().Context()
+	if old := currentSession(c); old != nil {
+		if err := h.sessions.Delete(ctx, old.Id); err != nil {
+			log.Printf("warn: failed to delete old session (id=%s): %v", old.Id, err)
+		}
+	}
+	if _, err := h.sessions.DeleteExpired(ctx); err != nil {
+		log.Printf("warn: %v", err)
+	}
+
+	maxAge := config.SessionMaxAge()
+	if tokenExpiresIn > 0 && tokenExpiresIn < maxAge {
+		maxAge = tokenExpiresIn
+	}
+	secret, _, err := h.sessions.Create(ctx, token, userID, c.Request().UserAgent(), time.Now().Add(maxAge))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create session").SetInternal(err)
+	}
+	setAuthCookie(c, cookieSessionKey, secret, maxAge)
+	return nil
+}
+
+// GET /api/auth/sessions
+// ログイン中のユーザーの有効なセッションの一覧（新しい順）
+func (h *Handler) GetSessions(c echo.Context) error {
+	cur := currentSession(c)
+	if cur == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	sessions, err := h.sessions.List(c.Request().Context(), cur.UserID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get sessions").SetInternal(err)
+	}
+	res := make([]sessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		res = append(res, sessionResponse{Session: s, Current: s.Id == cur.Id})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// DELETE /api/auth/sessions/:id
+// ログイン中のユーザーのセッションを失効させる（他の端末からのログアウト）。このリクエストのセッションならCookieも消す
+func (h *Handler) DeleteSession(c echo.Context) error {
+	cur := currentSession(c)
+	if cur == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid session ID")
+	}
+	if err := h.sessions.Revoke(c.Request().Context(), cur.UserID, id); err != nil {
+		if errors.Is(err, session.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke session").SetInternal(err)
+	}
+	if id == cur.Id {
+		delCookie(c, cookieSessionKey)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// traQの公開チャンネル一覧をキャッシュする期間（全ユーザーに同じ一覧が見えるため、リクエスト間で共有する）
+	channelCacheTTL = 5 * time.Minute
+	// 見つからないパスやIDが指定されたときに、キャッシュを作り直すまでの最短間隔
+	channelRefreshInterval = 30 * time.Second
+
+	channelIndexKey = "public"
+)
+
+var errTraqChannelNotFound = errors.New("traQ channel not found")
+
+// channelIndex は公開チャンネルの木構造とパスの対応
+type channelIndex struct {
+	channels map[string]traq.Channel
+	children map[string][]string // 親ID → 子ID（名前順）。ルートは "" の下に並べる
+	paths    map[string]string   // ID → "gps/times/alice"
+	ids      map[string]string   // 小文字のパス → ID
+	builtAt  time.Time
+}
+
+func newChannelIndex(list []traq.Channel) *channelIndex {
+	idx := &channelIndex{
+		channels: make(map[string]traq.Channel, len(list)),
+		children: make(map[string][]string),
+		paths:    make(map[string]string, len(list)),
+		ids:      make(map[string]string, len(list)),
+		builtAt:  time.Now(),
+	}
+	for _, ch := range list {
+		idx.channels[ch.ID] = ch
+	}
+	for _, ch := range list {
+		parent := ""
+		if ch.ParentID != nil {
+			parent = *ch.ParentID
+		}
+		idx.children[parent] = append(idx.children[parent], ch.ID)
+	}
+	for _, ids := range idx.children {
+		sort.Slice(ids, func(i, j int) bool { return idx.channels[ids[i]].Name < idx.channels[ids[j]].Name })
+	}
+
+	var walk func(parentPath, id string)
+	walk = func(parentPath, id string) {
+		path := idx.channels[id].Name
+		if parentPath != "" {
+			path = parentPath + "/" + path
+		}
+		idx.paths[id] = path
+		idx.ids[strings.ToLower(path)] = id
+		for _, child := range idx.children[id] {
+			walk(path, child)
+		}
+	}
+	for _, id := range idx.children[""] {
+		walk("", id)
+	}
+	return idx
+}
+
+// traqChannelNode はチャンネルの表示用の情報。Path は "#" を除いたフルパス
+type traqChannelNode struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Path     string            `json:"path"`
+	ParentID *string           `json:"parentId"`
+	Topic    string            `json:"topic"`
+	Archived bool              `json:"archived"`
+	Force    bool              `json:"force"`
+	Children []traqChannelNode `json:"children"`
+}
+
+// node はチャンネルの情報を子孫を含めて組み立てる。
+// depth は含める子孫の深さ（負なら全て）で、includeArchived が false ならアーカイブされたチャンネルを除く。
+func (idx *channelIndex) node(id string, depth int, includeArchived bool) traqChannelNode {
+	ch := idx.channels[id]
+	n := traqChannelNode{
+		ID:       ch.ID,
+		Name:     ch.Name,
+		Path:     idx.paths[id],
+		ParentID: ch.ParentID,
+		Topic:    ch.Topic,
+		Archived: ch.Archived,
+		Force:    ch.Force,
+		Children: []traqChannelNode{},
+	}
+	if depth == 0 {
+		return n
+	}
+	for _, child := range idx.children[id] {
+		if !includeArchived && idx.channels[child].Archived {
+			continue
+		}
+		n.Children = append(n.Children, idx.node(child, depth-1, includeArchived))
+	}
+	return n
+}
+
+// GetTraqChannels
+// GET /api/v1/traq/channels?archived=true
+// 公開チャンネルを木構造で返す。archived=true でアーカイブされたチャンネルも含める。
+func (h *Handler) GetTraqChannels(c echo.Context) error {
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	includeArchived := c.QueryParam("archived") == "true" || c.QueryParam("archived") == "1"
+
+	idx, err := h.getChannelIndex(c.Request().Context(), token, false)
+	if err != nil {
+		return traqHTTPError(err, "failed to request traQ")
+	}
+
+	roots := make([]traqChannelNode, 0, len(idx.children[""]))
+	for _, id := range idx.children[""] {
+		if !includeArchived && idx.channels[id].Archived {
+			continue
+		}
+		roots = append(roots, idx.node(id, -1, includeArchived))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"channels": roots,
+	})
+}
+
+// GetTraqChannelByID
+// GET /api/v1/traq/channels/:id
+// チャンネルの情報をパスと直下の子チャンネルとともに返す
+func (h *Handler) GetTraqChannelByID(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "id is required")
+	}
+
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	ctx := c.Request().Context()
+
+	idx, err := h.getChannelIndex(ctx, token, false)
+	if err != nil {
+		return traqHTTPError(err, "failed to request traQ")
+	}
+	if _, ok := idx.channels[id]; !ok {
+		// キャッシュ後に作られたチャンネルかもしれないので、traQに存在を確認してから作り直す。
+		// 一覧に載らないチャンネル（プライベートチャンネルなど）で毎回作り直さないよう、一定間隔をあける
+		if _, err := h.traq.GetChannel(ctx, token, id); err != nil {
+			return traqHTTPError(err, "failed to request traQ")
+		}
+		if time.Since(idx.builtAt) < channelRefreshInterval {
+			return echo.NewHTTPError(http.StatusNotFound, "channel not found")
+		}
+		if idx, err = h.getChannelIndex(ctx, token, true); err != nil {
+			return traqHTTPError(err, "failed to request traQ")
+		}
+		if _, ok := idx.channels[id]; !ok {
+			return echo.NewHTTPError(http.StatusNotFound, "channel not found")
+		}
+	}
+
+	return c.JSON(http.StatusOK, idx.node(id, 1, true))
+}
+
+// GetTraqChannelByPath
+// GET /api/v1/traq/channels/resolve?path=#gps/times/alice
+// チャンネルのパスからチャンネルを探す。パスの大文字・小文字は区別しない。
+func (h *Handler) GetTraqChannelByPath(c echo.Context) error {
+	path := c.QueryParam("path")
+	if normalizeChannelPath(path) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "path is required")
+	}
+
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	idx, id, err := h.resolveChannelPath(c.Request().Context(), token, path)
+	if err != nil {
+		if errors.Is(err, errTraqChannelNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "channel not found")
+		}
+		return traqHTTPError(err, "failed to request traQ")
+	}
+	return c.JSON(http.StatusOK, idx.node(id, 0, true))
+}
+
+// normalizeChannelPath は "#gps/times/alice" や "gps/times/alice/" を "gps/times/alice" の形にそろえる
+func normalizeChannelPath(path string) string {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "#")
+	return strings.ToLower(strings.Trim(path, "/"))
+}
+
+// resolveChannelPath はチャンネルのパスをIDに変換する。見つからなければ errTraqChannelNotFound を返す。
+func (h *Handler) resolveChannelPath(ctx context.Context, token, path string) (*channelIndex, string, error) {
+	key := normalizeChannelPath(path)
+
+	idx, err := h.getChannelIndex(ctx, token, false)
+	if err != nil {
+		return nil, "", err
+	}
+	if id, ok := idx.ids[key]; ok {
+		return idx, id, nil
+	}
+
+	// キャッシュ後に作られたチャンネルかもしれないので、一定間隔をあけて作り直す
+	if time.Since(idx.builtAt) < channelRefreshInterval {
+		return nil, "", errTraqChannelNotFound
+	}
+	if idx, err = h.getChannelIndex(ctx, token, true); err != nil {
+		return nil, "", err
+	}
+	if id, ok := idx.ids[key]; ok {
+		return idx, id, nil
+	}
+	return nil, "", errTraqChannelNotFound
+}
+
+// isPublicChannel は公開チャンネル（アーカイブされたものを含む）かを返す。
+// キャッシュに無ければ、キャッシュ後に作られたチャンネルかもしれないので一定間隔をあけて一覧を取得し直す。
+func (h *Handler) isPublicChannel(ctx context.Context, token, id string) (bool, error) {
+	idx, err := h.getChannelIndex(ctx, token, false)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := idx.channels[id]; ok {
+		return true, nil
+	}
+	if time.Since(idx.builtAt) < channelRefreshInterval {
+		return false, nil
+	}
+	if idx, err = h.getChannelIndex(ctx, token, true); err != nil {
+		return false, err
+	}
+	_, ok := idx.channels[id]
+	return ok, nil
+}
+
+// getChannelIndex は公開チャンネルの一覧をキャッシュから、無ければ（refresh なら常に）traQから取得する。
+// 同時に取得が必要になった場合は1回にまとめる（doSharedTraq）。
+func (h *Handler) getChannelIndex(ctx context.Context, token string, refresh bool) (*channelIndex, error) {
+	if !refresh {
+		if idx, ok := h.channelCache.Get(channelIndexKey); ok {
+			return idx, nil
+		}
+	}
+
+	v, err := doSharedTraq(&h.channelGroup, channelIndexKey, func() (interface{}, error) {
+		// 呼び出し元のキャンセルが他の待機中のリクエストに波及しないようにする
+		list, err := h.traq.GetChannels(context.WithoutCancel(ctx), token)
+		if err != nil {
+			return nil, err
+		}
+		idx := newChannelIndex(list.Public)
+		h.channelCache.Set(channelIndexKey, idx)
+		return idx, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*channelIndex), nil
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"github.com/labstack/echo/v4"
+)
+
+// traQ のステータスコードごとのエラーコード。ここに無いステータスは 502 traq_error として返す
+var traqErrorCodes = map[int]string{
+	http.StatusBadRequest:                   "traq_bad_request",
+	http.StatusUnauthorized:                 "traq_unauthorized",
+	http.StatusForbidden:                    "traq_forbidden",
+	http.StatusNotFound:                     "traq_not_found",
+	http.StatusRequestedRangeNotSatisfiable: "traq_range_not_satisfiable",
+	http.StatusTooManyRequests:              "traq_rate_limited",
+}
+
+// traqErrorDetails は traQ が返したエラーの内容
+type traqErrorDetails struct {
+	Status  int    `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// traqHTTPError は traQ 呼び出しのエラーをクライアント向けのエラーに変換する。
+// traQ が返した 4xx（traqErrorCodes にあるもの）はそのままのステータスで、traQ が停止している間は 503、それ以外は 502 として返す。
+// err が既にクライアント向けのエラーならそのまま返す。
+func traqHTTPError(err error, message string) error {
+	var ae *apiError
+	var he *echo.HTTPError
+	if errors.As(err, &ae) || errors.As(err, &he) {
+		return err
+	}
+	if traqUnavailable(err) {
+		return newAPIError(http.StatusServiceUnavailable, "traq_unavailable", "traQ is temporarily unavailable").withInternal(err)
+	}
+
+	var apiErr *traq.APIError
+	if !errors.As(err, &apiErr) {
+		return newAPIError(http.StatusBadGateway, "traq_error", message).withInternal(err)
+	}
+	details := traqErrorDetails{Status: apiErr.StatusCode}
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(apiErr.Body, &body) == nil {
+		details.Message = body.Message
+	}
+	if code, ok := traqErrorCodes[apiErr.StatusCode]; ok {
+		return newAPIError(apiErr.StatusCode, code, message).withDetails(details).withInternal(err)
+	}
+	return newAPIError(http.StatusBadGateway, "traq_error", message).withDetails(details).withInternal(err)
+}
+
+// traqWriteHTTPError は traQ への書き込み（メッセージの投稿やスタンプ）のエラーをクライアント向けのエラーに変換する。
+// traQ が 403 を返したときは、ログイン時に write スコープを許可していないとみなして 403 write_scope_required と
+// 再認可のURL（認可後に callback へ戻る）を返す。それ以外は traqHTTPError と同じ。
+func traqWriteHTTPError(err error, message, callback string) error {
+	if traq.StatusCode(err) != http.StatusForbidden {
+		return traqHTTPError(err, message)
+	}
+	reauthorizeURL := "/api/auth/request"
+	if callback != "" {
+		reauthorizeURL += "?callback=" + url.QueryEscape(callback)
+	}
+	return newAPIError(http.StatusForbidden, "write_scope_required", "writing to traQ is not permitted; sign in again and allow the write scope").
+		withDetails(map[string]string{"reauthorize_url": reauthorizeURL}).
+		withInternal(err)
+}
+
+// traqUnavailable は traQ が停止している（circuit breaker が開いている、または再試行しても 503 が返った）かを返す
+func traqUnavailable(err error) bool {
+	return errors.Is(err, traq.ErrCircuitOpen) || traq.StatusCode(err) == http.StatusServiceUnavailable
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/cache"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// キャッシュした内容をtraQに再検証せずに返す期間
+	fileCacheFreshFor = 10 * time.Minute
+	// ユーザーがファイルにアクセスできることを確認してから、再確認せずにキャッシュを返す期間
+	fileAccessMemoTTL = 5 * time.Minute
+	// アクセス確認の記録の上限件数
+	fileAccessMemoMaxEntries = 100_000
+)
+
+// fileCacheMetrics はファイルキャッシュの利用状況
+type fileCacheMetrics struct {
+	// traQへのリクエスト無しでキャッシュから返した回数
+	hits atomic.Int64
+	// traQへの条件付きリクエスト(304)で再検証してキャッシュから返した回数
+	revalidated atomic.Int64
+	// traQから取得した回数
+	misses atomic.Int64
+}
+
+type fileFetcher func(ctx context.Context, token, fileID string, header http.Header) (*http.Response, error)
+
+// fileAccessKey はトークンそのものを保持しないようにハッシュ化したキー
+func fileAccessKey(token, fileID string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16]) + ":" + fileID
+}
+
+func (h *Handler) hasFileAccess(token, fileID string) bool {
+	_, ok := h.fileAccess.Get(fileAccessKey(token, fileID))
+	return ok
+}
+
+func (h *Handler) rememberFileAccess(token, fileID string) {
+	h.fileAccess.Set(fileAccessKey(token, fileID), struct{}{})
+}
+
+// serveTraqFile はtraQのファイル（またはサムネイル）をディスクキャッシュを介して返す。
+// キャッシュから返すのは、直近にそのユーザーがファイルにアクセスできることを確認済みで、
+// かつ内容が新しい場合のみ。それ以外はtraQに条件付きリクエストを送って認可と内容を確認する。
+func (h *Handler) serveTraqFile(c echo.Context, token, fileID, key string, fetch fileFetcher) error {
+	ctx := c.Request().Context()
+
+	if h.fileCache == nil {
+		resp, err := fetch(ctx, token, fileID, forwardedFileHeaders(c.Request()))
+		if err != nil {
+			return traqHTTPError(err, "failed to fetch file from traQ")
+		}
+		defer closeResponseBody(resp)
+		return h.proxyResponse(c, resp)
+	}
+
+	if f, meta, ok := h.fileCache.Open(key); ok {
+		defer func() {
+			if cerr := f.Close(); cerr != nil {
+				log.Printf("warn: failed to close cached file: %v", cerr)
+			}
+		}()
+
+		if h.hasFileAccess(token, fileID) && time.Since(meta.ValidatedAt) < fileCacheFreshFor {
+			h.fileCacheMetrics.hits.Add(1)
+			return writeCachedFile(c, f, meta, "HIT")
+		}
+
+		header := http.Header{}
+		if meta.ETag != "" {
+			header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			header.Set("If-Modified-Since", meta.LastModified)
+		}
+		resp, err := fetch(ctx, token, fileID, header)
+		if err != nil {
+			return traqHTTPError(err, "failed to fetch file from traQ")
+		}
+		defer closeResponseBody(resp)
+
+		if resp.StatusCode == http.StatusNotModified {
+			h.rememberFileAccess(token, fileID)
+			h.fileCache.MarkValidated(key, time.Now())
+			h.fileCacheMetrics.revalidated.Add(1)
+			return writeCachedFile(c, f, meta, "REVALIDATED")
+		}
+		return h.storeTraqFile(c, token, fileID, key, resp)
+	}
+
+	resp, err := fetch(ctx, token, fileID, forwardedFileHeaders(c.Request()))
+	if err != nil {
+		return traqHTTPError(err, "failed to fetch file from traQ")
+	}
+	defer closeResponseBody(resp)
+	return h.storeTraqFile(c, token, fileID, key, resp)
+}
+
+// forwardedFileHeaders はクライアントの条件付きリクエスト・範囲リクエストのヘッダーをtraQに転送するために取り出す
+func forwardedFileHeaders(req *http.Request) http.Header {
+	header := http.Header{}
+	for _, k := range []string{"If-None-Match", "If-Modified-Since", "Range", "If-Range"} {
+		if v := req.Header.Get(k); v != "" {
+			header.Set(k, v)
+		}
+	}
+	return header
+}
+
+// storeTraqFile はtraQのレスポンスをクライアントに転送しつつ、全体を取得できた場合はキャッシュに保存する。
+// 部分的なレスポンス(206)や304はそのまま転送する。
+func (h *Handler) storeTraqFile(c echo.Context, token, fileID, key string, resp *http.Response) error {
+	h.fileCacheMetrics.misses.Add(1)
+	c.Response().Header().Set("X-Cache", "MISS")
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusPartialContent, http.StatusNotModified:
+		h.rememberFileAccess(token, fileID)
+		return h.proxyResponse(c, resp)
+	case http.StatusNotFound:
+		// 削除されたファイルはキャッシュからも消す（権限エラーはユーザーごとの問題なので残す）
+		h.fileCache.Remove(key)
+		return h.proxyResponse(c, resp)
+	default:
+		return h.proxyResponse(c, resp)
+	}
+	h.rememberFileAccess(token, fileID)
+
+	w := h.fileCache.NewWriter(key, cache.DiskMeta{
+		ContentType:  resp.Header.Get("Content-Type"),
+		CacheControl: resp.Header.Get("Cache-Control"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	})
+
+	copyProxyHeaders(c, resp)
+	c.Response().WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(io.MultiWriter(c.Response(), w), resp.Body); err != nil {
+		w.Abort()
+		return err
+	}
+	w.Commit()
+	return nil
+}
+
+// writeCachedFile はキャッシュした内容を返す。
+// クライアントの条件付きリクエスト(304)と範囲リクエスト(206)には http.ServeContent が応答する。
+func writeCachedFile(c echo.Context, f *os.File, meta cache.DiskMeta, status string) error {
+	header := c.Response().Header()
+	if meta.ContentType != "" {
+		header.Set("Content-Type", meta.ContentType)
+	}
+	if meta.CacheControl != "" {
+		header.Set("Cache-Control", meta.CacheControl)
+	}
+	if meta.ETag != "" {
+		header.Set("ETag", meta.ETag)
+	}
+	header.Set("X-Cache", status)
+
+	var modTime time.Time
+	if meta.LastModified != "" {
+		if t, err := http.ParseTime(meta.LastModified); err == nil {
+			modTime = t
+		}
+	}
+	http.ServeContent(c.Response(), c.Request(), "", modTime, f)
+	return nil
+}
+
+func closeResponseBody(resp *http.Response) {
+	if cerr := resp.Body.Close(); cerr != nil {
+		log.Printf("warn: failed to close response body: %v", cerr)
+	}
+}
+
+// GetTraqFileCacheStats
+// GET /api/v1/traq/files/cache/stats
+// ファイルキャッシュのヒット率と使用量を返す（他の traQ プロキシと同じくログインが必要）
+func (h *Handler) GetTraqFileCacheStats(c echo.Context) error {
+	if getSessionToken(c) == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	hits := h.fileCacheMetrics.hits.Load()
+	revalidated := h.fileCacheMetrics.revalidated.Load()
+	misses := h.fileCacheMetrics.misses.Load()
+
+	hitRatio := 0.0
+	if total := hits + revalidated + misses; total > 0 {
+		hitRatio = float64(hits+revalidated) / float64(total)
+	}
+
+	res := map[string]interface{}{
+		"enabled":     h.fileCache != nil,
+		"hits":        hits,
+		"revalidated": revalidated,
+		"misses":      misses,
+		"hitRatio":    hitRatio,
+	}
+	if h.fileCache != nil {
+		stats := h.fileCache.Stats()
+		res["entries"] = stats.Entries
+		res["sizeBytes"] = stats.Size
+		res["maxSizeBytes"] = stats.MaxSize
+		res["evictions"] = stats.Evictions
+	}
+	return c.JSON(http.StatusOK, res)
+}
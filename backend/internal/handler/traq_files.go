@@ -2,13 +2,15 @@ package handler
 
 import (
 	"io"
-	"log"
 	"net/http"
 
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
 	"github.com/labstack/echo/v4"
 )
 
 // GET /api/v1/traq/files/{uuid}
+// query: w, h, fit (optional, contain|cover) — 指定時はサーバー側でリサイズした画像を返す
 // traQ APIのファイル本体を取得してプロキシする
 func (h *Handler) GetTraqFile(c echo.Context) error {
 	uuid := c.Param("uuid")
@@ -16,25 +18,23 @@ func (h *Handler) GetTraqFile(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "uuid is required")
 	}
 
+	variant, resize, err := parseVariantParams(c)
+	if err != nil {
+		return err
+	}
+
 	// CookieからtraQ認証トークンを取得
-	token := getTokenFromCookie(c)
+	token := getSessionToken(c)
 	if token == "" {
 		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
 	}
 
-	// traQ APIにリクエストを送信
-	resp, err := h.proxyTraqFileRequest("https://q.trap.jp/api/v3/files/"+uuid, token)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch file from traQ").SetInternal(err)
+	if resize {
+		return h.getTraqFileVariant(c, uuid, token, variant)
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			log.Printf("warn: failed to close response body: %v", cerr)
-		}
-	}()
-
-	// traQ APIのレスポンスをそのままクライアントに返す
-	return h.proxyResponse(c, resp)
+
+	// キャッシュまたはtraQ APIのレスポンスを返す
+	return h.serveTraqFile(c, token, uuid, uuid, h.traq.GetFile)
 }
 
 // GET /api/v1/traq/files/{uuid}/thumbnail
@@ -46,50 +46,36 @@ func (h *Handler) GetTraqFileThumbnail(c echo.Context) error {
 	}
 
 	// CookieからtraQ認証トークンを取得
-	token := getTokenFromCookie(c)
+	token := getSessionToken(c)
 	if token == "" {
 		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
 	}
 
-	// traQ APIにリクエストを送信
-	resp, err := h.proxyTraqFileRequest("https://q.trap.jp/api/v3/files/"+uuid+"/thumbnail", token)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch thumbnail from traQ").SetInternal(err)
-	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			log.Printf("warn: failed to close response body: %v", cerr)
-		}
-	}()
-
-	// traQ APIのレスポンスをそのままクライアントに返す
-	return h.proxyResponse(c, resp)
+	// キャッシュまたはtraQ APIのレスポンスを返す
+	return h.serveTraqFile(c, token, uuid, uuid+"/thumbnail", h.traq.GetFileThumbnail)
 }
 
-// traQ APIへのHTTPリクエストを送信する共通関数
-func (h *Handler) proxyTraqFileRequest(url, token string) (*http.Response, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
+// traQ APIのレスポンスをクライアントにそのまま転送する共通関数
+func (h *Handler) proxyResponse(c echo.Context, resp *http.Response) error {
+	// traQのエラーは共通のエラーレスポンスに変換する
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return traqHTTPError(&traq.APIError{StatusCode: resp.StatusCode, Body: body}, "failed to fetch file from traQ")
 	}
 
-	// AuthorizationヘッダーにtraQトークンを設定
-	req.Header.Set("Authorization", "Bearer "+token)
+	copyProxyHeaders(c, resp)
 
-	resp, err := h.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
+	// ステータスコードを設定
+	c.Response().WriteHeader(resp.StatusCode)
 
-	return resp, nil
+	// レスポンスボディをそのままコピー
+	// （ファイル本体は、クライアントが切断するとリクエストのコンテキストと共にtraQへのリクエストも中断される）
+	_, err := io.Copy(c.Response(), resp.Body)
+	return err
 }
 
-// traQ APIのレスポンスをクライアントにそのまま転送する共通関数
-func (h *Handler) proxyResponse(c echo.Context, resp *http.Response) error {
-	// ステータスコードを設定
-	c.Response().Status = resp.StatusCode
-
-	// 重要なヘッダーをコピー
+// copyProxyHeaders はtraQのレスポンスの重要なヘッダーをクライアントへのレスポンスにコピーする
+func copyProxyHeaders(c echo.Context, resp *http.Response) {
 	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
 		c.Response().Header().Set("Content-Type", contentType)
 	}
@@ -99,15 +85,16 @@ func (h *Handler) proxyResponse(c echo.Context, resp *http.Response) error {
 	if cacheControl := resp.Header.Get("Cache-Control"); cacheControl != "" {
 		c.Response().Header().Set("Cache-Control", cacheControl)
 	}
+	if contentRange := resp.Header.Get("Content-Range"); contentRange != "" {
+		c.Response().Header().Set("Content-Range", contentRange)
+	}
+	if acceptRanges := resp.Header.Get("Accept-Ranges"); acceptRanges != "" {
+		c.Response().Header().Set("Accept-Ranges", acceptRanges)
+	}
 	if etag := resp.Header.Get("ETag"); etag != "" {
 		c.Response().Header().Set("ETag", etag)
 	}
 	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
 		c.Response().Header().Set("Last-Modified", lastModified)
 	}
-
-	// レスポンスボディをそのままコピー
-	// エラーレスポンスも含めて、すべてのレスポンスをそのまま転送
-	_, err := io.Copy(c.Response().Writer, resp.Body)
-	return err
 }
@@ -1,225 +1,100 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
-	"net/url"
-	"regexp"
 	"strconv"
 
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
-// traqMessageSearchParams は traQ /api/v3/messages のクエリに対応する内部用パラメータです。
-type traqMessageSearchParams struct {
-	Word           string
-	After          string // RFC3339想定 (serverはバリデーションしない)
-	Before         string // RFC3339想定 (serverはバリデーションしない)
-	In             string // channel uuid
-	To             []string
-	From           []string
-	Citation       string // message uuid
-	Bot            *bool
-	HasURL         *bool
-	HasAttachments *bool
-	HasImage       *bool
-	HasVideo       *bool
-	HasAudio       *bool
-	Limit          *int
-	Offset         *int
-	Sort           string // createdAt | -createdAt | updatedAt | -updatedAt
-}
+const (
+	// traQ検索APIの limit の既定値
+	defaultTraqSearchLimit = 20
+	// スタンプで絞り込む際に、1回の検索で traQ にリクエストする回数の上限
+	maxStampFilterUpstreamCalls = 5
+)
 
-// searchTraqMessages は traQ のメッセージ検索APIをそのまま実行し、レスポンスボディ(JSON)を返します。
-// HTTP経由でそのまま返すのではなく、後続処理用の生データ取得に利用します。
-// 返り値: body, statusCode, error
-func (h *Handler) searchTraqMessages(c echo.Context, p *traqMessageSearchParams) ([]byte, int, error) {
-	token := getTokenFromCookie(c)
+// searchTraqMessages は traQ のメッセージ検索APIをリクエストのトークンで実行します。
+func (h *Handler) searchTraqMessages(c echo.Context, p *traq.MessageSearchParams) (*traq.MessageSearchResult, error) {
+	token := getSessionToken(c)
 	if token == "" {
-		return nil, http.StatusUnauthorized, echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
-	}
-
-	if p == nil {
-		p = &traqMessageSearchParams{}
-	}
-
-	endpoint, _ := url.Parse("https://q.trap.jp/api/v3/messages")
-	q := endpoint.Query()
-
-	if p.Word != "" {
-		q.Set("word", p.Word)
-	}
-	if p.After != "" {
-		q.Set("after", p.After)
-	}
-	if p.Before != "" {
-		q.Set("before", p.Before)
-	}
-	if p.In != "" {
-		q.Set("in", p.In)
-	}
-	for _, v := range p.To {
-		if v != "" {
-			q.Add("to", v)
-		}
-	}
-	for _, v := range p.From {
-		if v != "" {
-			q.Add("from", v)
-		}
-	}
-	if p.Citation != "" {
-		q.Set("citation", p.Citation)
-	}
-	if p.Bot != nil {
-		q.Set("bot", strconv.FormatBool(*p.Bot))
-	}
-	if p.HasURL != nil {
-		q.Set("hasURL", strconv.FormatBool(*p.HasURL))
-	}
-	if p.HasAttachments != nil {
-		q.Set("hasAttachments", strconv.FormatBool(*p.HasAttachments))
-	}
-	if p.HasImage != nil {
-		q.Set("hasImage", strconv.FormatBool(*p.HasImage))
-	}
-	if p.HasVideo != nil {
-		q.Set("hasVideo", strconv.FormatBool(*p.HasVideo))
-	}
-	if p.HasAudio != nil {
-		q.Set("hasAudio", strconv.FormatBool(*p.HasAudio))
-	}
-	if p.Limit != nil {
-		q.Set("limit", strconv.Itoa(*p.Limit))
-	}
-	if p.Offset != nil {
-		q.Set("offset", strconv.Itoa(*p.Offset))
-	}
-	if p.Sort != "" {
-		q.Set("sort", p.Sort)
-	}
-
-	endpoint.RawQuery = q.Encode()
-
-	req, err := http.NewRequestWithContext(c.Request().Context(), http.MethodGet, endpoint.String(), nil)
-	if err != nil {
-		return nil, 0, err
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			log.Printf("warn: failed to close response body: %v", cerr)
-		}
-	}()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, resp.StatusCode, err
-	}
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		// 生のエラーボディを付与
-		return body, resp.StatusCode, fmt.Errorf("traQ messages search failed: status=%d body=%s", resp.StatusCode, string(body))
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
 	}
 
-	return body, resp.StatusCode, nil
+	return h.traq.SearchMessages(c.Request().Context(), token, p)
 }
 
 // ---------- 画像UUID抽出用の内部処理 ----------
 
-// traqMessagesRawResponse は最小限のフィールドのみ定義
-type traqMessagesRawResponse struct {
-	TotalHits int `json:"totalHits"`
-	Hits      []struct {
-		Content string `json:"content"`
-	} `json:"hits"`
-}
-
-// traQ検索レスポンスの全体を保持するための最小Envelope。
-// hitsは生のまま(json.RawMessage)で持ち、必要最小限だけ部分デコードする。
-type traqMessagesEnvelope struct {
-	TotalHits int                `json:"totalHits"`
-	Hits      []json.RawMessage `json:"hits"`
-}
-
-// stampsフィールドだけの部分ビュー
-type traqMessageStampView struct {
-	Stamps []struct {
-		StampID string `json:"stampId"`
-	} `json:"stamps"`
+// メッセージ本文からファイルUUIDを全件抽出する
+func (h *Handler) extractUUIDsFromContent(content string) []string {
+	return h.traq.ExtractFileIDs(content)
 }
 
-var fileUUIDRe = regexp.MustCompile(`https?://q\.trap\.jp/files/([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})`)
-
-// メッセージ本文からファイルUUIDを全件抽出する
-func extractUUIDsFromContent(content string) []string {
-	matches := fileUUIDRe.FindAllStringSubmatch(content, -1)
-	if len(matches) == 0 {
-		return nil
-	}
-	res := make([]string, 0, len(matches))
-	for _, m := range matches {
-		if len(m) >= 2 {
-			res = append(res, m[1])
-		}
+// nextSearchOffset は絞り込みをしない検索で、続きを取得するときの traQ の offset を返す。続きが無ければ -1。
+func nextSearchOffset(p *traq.MessageSearchParams, res *traq.MessageSearchResult) int {
+	offset := 0
+	if p.Offset != nil {
+		offset = *p.Offset
 	}
-	return res
+	next := offset + len(res.Hits)
+	if len(res.Hits) == 0 || next >= res.TotalHits {
+		return -1
+	}
+	return next
 }
 
-// hasImage=true 固定で traQ 検索を行い、必要に応じて stampId で hits をフィルタし、
-// totalHits と content から抽出した画像UUIDの配列を返す。
-func (h *Handler) searchTraqImagesUUIDs(c echo.Context, p *traqMessageSearchParams, stampID string) (int, []string, error) {
+// hasImage=true 固定で traQ 検索を行い、必要に応じてスタンプで hits をフィルタする。
+// 続きを取得するときの traQ の offset（続きが無ければ -1）も返す。
+func (h *Handler) searchTraqImageMessages(c echo.Context, p *traq.MessageSearchParams, filter *stampFilter) (*traq.MessageSearchResult, int, error) {
 	// callerからのHasImage指定は無視してtrue固定
 	has := true
 	if p == nil {
-		p = &traqMessageSearchParams{}
+		p = &traq.MessageSearchParams{}
 	}
 	p.HasImage = &has
 
-	var (
-		body   []byte
-		status int
-		err    error
-	)
-	if stampID != "" {
-		body, status, err = h.searchTraqMessagesWithStampFilter(c, p, stampID)
-	} else {
-		body, status, err = h.searchTraqMessages(c, p)
+	if filter != nil {
+		return h.searchTraqMessagesWithStampFilter(c, p, filter)
 	}
+	res, err := h.searchTraqMessages(c, p)
 	if err != nil {
-		// 上位で扱いやすいように、traQのステータスも含める
-		return 0, nil, fmt.Errorf("traQ search failed (status=%d): %w", status, err)
-	}
-
-	var raw traqMessagesRawResponse
-	if err := json.Unmarshal(body, &raw); err != nil {
-		return 0, nil, err
+		return nil, 0, err
 	}
+	return res, nextSearchOffset(p, res), nil
+}
 
+// メッセージの content から画像UUIDを抽出し、メッセージ順に並べて返す（重複は除かない）
+func (h *Handler) imageUUIDsFromMessages(messages []traq.Message) []string {
 	uuids := make([]string, 0)
-	for _, h := range raw.Hits {
-		if h.Content == "" {
+	for _, m := range messages {
+		if m.Content == "" {
 			continue
 		}
-		found := extractUUIDsFromContent(h.Content)
+		found := h.extractUUIDsFromContent(m.Content)
 		if len(found) > 0 {
 			uuids = append(uuids, found...)
 		}
 	}
-	return raw.TotalHits, uuids, nil
+	return uuids
 }
 
-// traQ検索を行い、totalHits と抽出した画像UUID配列を返す。
+// traQ検索を行い、totalHits（スタンプで絞り込んだ場合は省略）と抽出した画像UUID配列を返す。
+// hitFormat=rich の場合は、hits を投稿元メッセージの情報付きのオブジェクト（画像ごとに1件）で返す。
+// nextCursor を cursor に指定すると続きを取得できる（続きが無ければ null）。
+// source=local の場合はtraQではなくアプリが把握している画像から検索する。
 func (h *Handler) GetTraqMessagesSearchImages(c echo.Context) error {
-	params := &traqMessageSearchParams{
+	if c.QueryParam("source") == "local" {
+		return h.searchLocalImages(c)
+	}
+
+	params := &traq.MessageSearchParams{
 		Word:     c.QueryParam("word"),
 		After:    c.QueryParam("after"),
 		Before:   c.QueryParam("before"),
@@ -227,7 +102,18 @@ func (h *Handler) GetTraqMessagesSearchImages(c echo.Context) error {
 		Citation: c.QueryParam("citation"),
 		Sort:     c.QueryParam("sort"),
 	}
-	stampID := c.QueryParam("stampId")
+	filter, err := h.stampFilterFromQuery(c)
+	if err != nil {
+		return err
+	}
+	rich := false
+	switch c.QueryParam("hitFormat") {
+	case "", "ids":
+	case "rich":
+		rich = true
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "hitFormat must be ids or rich")
+	}
 	if v := c.QueryParam("bot"); v != "" {
 		b := v == "true" || v == "1"
 		params.Bot = &b
@@ -244,16 +130,71 @@ func (h *Handler) GetTraqMessagesSearchImages(c echo.Context) error {
 	}
 	params.To = c.QueryParams()["to"]
 	params.From = c.QueryParams()["from"]
+	if err := applySearchCursor(c, params); err != nil {
+		return err
+	}
+
+	// channel=gps/times/alice のようにチャンネルをパスで指定できる
+	if path := c.QueryParam("channel"); path != "" {
+		token := getSessionToken(c)
+		if token == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+		}
+		_, id, err := h.resolveChannelPath(c.Request().Context(), token, path)
+		if err != nil {
+			if errors.Is(err, errTraqChannelNotFound) {
+				return echo.NewHTTPError(http.StatusBadRequest, "unknown channel: "+path)
+			}
+			return traqHTTPError(err, "failed to resolve channel")
+		}
+		if params.In != "" && params.In != id {
+			return echo.NewHTTPError(http.StatusBadRequest, "in and channel specify different channels")
+		}
+		params.In = id
+	}
 
-	total, uuids, err := h.searchTraqImagesUUIDs(c, params, stampID)
+	res, nextOffset, err := h.searchTraqImageMessages(c, params, filter)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return traqHTTPError(err, "traQ search failed")
+	}
+
+	var (
+		hits  interface{}
+		uuids []string
+	)
+	if rich {
+		richHits := h.imageHitsFromMessages(res.Hits)
+		uuids = make([]string, 0, len(richHits))
+		for _, hit := range richHits {
+			uuids = append(uuids, hit.ID)
+		}
+		hits = richHits
+	} else {
+		uuids = h.imageUUIDsFromMessages(res.Hits)
+		hits = uuids
+	}
+
+	// プレースホルダー情報（解析済みの画像のみ）
+	ids := make([]uuid.UUID, 0, len(uuids))
+	for _, s := range uuids {
+		if id, err := uuid.Parse(s); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	placeholders, err := h.imagePlaceholders(c.Request().Context(), getSessionToken(c), ids)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to retrieve image placeholders").SetInternal(err)
 	}
 
 	// レスポンス整形
 	out := map[string]interface{}{
-		"totalHits": total,
-		"hits":      uuids,
+		"hits":         hits,
+		"placeholders": placeholders,
+		"nextCursor":   nextSearchCursor(nextOffset),
+	}
+	// 絞り込み後の件数は分からないため、スタンプフィルタ指定時は totalHits を返さない
+	if filter == nil {
+		out["totalHits"] = res.TotalHits
 	}
 	return c.JSON(http.StatusOK, out)
 }
@@ -261,7 +202,7 @@ func (h *Handler) GetTraqMessagesSearchImages(c echo.Context) error {
 // 透過プロキシエンドポイント。
 func (h *Handler) GetTraqMessagesSearch(c echo.Context) error {
 	// クエリを構築
-	params := &traqMessageSearchParams{
+	params := &traq.MessageSearchParams{
 		Word:     c.QueryParam("word"),
 		After:    c.QueryParam("after"),
 		Before:   c.QueryParam("before"),
@@ -307,63 +248,92 @@ func (h *Handler) GetTraqMessagesSearch(c echo.Context) error {
 	// 多値クエリ
 	params.To = c.QueryParams()["to"]
 	params.From = c.QueryParams()["from"]
+	if err := applySearchCursor(c, params); err != nil {
+		return err
+	}
 
 	// スタンプフィルタ（オプション）
-	if stampID := c.QueryParam("stampId"); stampID != "" {
-		body, status, err := h.searchTraqMessagesWithStampFilter(c, params, stampID)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-		}
-		return c.Blob(status, "application/json", body)
+	filter, err := h.stampFilterFromQuery(c)
+	if err != nil {
+		return err
+	}
+	var (
+		res        *traq.MessageSearchResult
+		nextOffset int
+	)
+	if filter != nil {
+		res, nextOffset, err = h.searchTraqMessagesWithStampFilter(c, params, filter)
+	} else if res, err = h.searchTraqMessages(c, params); err == nil {
+		nextOffset = nextSearchOffset(params, res)
 	}
-
-	// traQのエラーはそのまま返す（検証用途）
-	body, status, _ := h.searchTraqMessages(c, params)
-	return c.Blob(status, "application/json", body)
-}
-
-// searchTraqMessagesWithStampFilter は searchTraqMessages と同等の検索を行い、
-// 返却する hits を指定した stampId を含むものだけに絞り込みます。
-// totalHits などは traQ の値をそのまま返します（再計算しません）。
-func (h *Handler) searchTraqMessagesWithStampFilter(c echo.Context, p *traqMessageSearchParams, stampID string) ([]byte, int, error) {
-	body, status, err := h.searchTraqMessages(c, p)
 	if err != nil {
-		// traQからのエラーはそのまま上位へ
-		return body, status, err
+		return traqHTTPError(err, "traQ search failed")
 	}
-
-	var env traqMessagesEnvelope
-	if err := json.Unmarshal(body, &env); err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("failed to decode traQ messages: %w", err)
+	body := map[string]interface{}{
+		"hits":       res.Hits,
+		"nextCursor": nextSearchCursor(nextOffset),
 	}
-
-	if len(env.Hits) == 0 {
-		// 空ならそのまま返す
-		return body, status, nil
+	// 絞り込み後の件数は分からないため、スタンプフィルタ指定時は totalHits を返さない
+	if filter == nil {
+		body["totalHits"] = res.TotalHits
 	}
+	return c.JSON(http.StatusOK, body)
+}
 
-	filtered := make([]json.RawMessage, 0, len(env.Hits))
-	for _, raw := range env.Hits {
-		var v traqMessageStampView
-		if err := json.Unmarshal(raw, &v); err != nil {
-			// 1件の失敗で全体を止めない。読み飛ばし。ただしエラーはログに記録する。
-			log.Printf("failed to unmarshal traq message: %v (raw: %.100s)", err, string(raw))
-			continue
+// searchTraqMessagesWithStampFilter は searchTraqMessages と同等の検索を行い、
+// 返却する hits をスタンプの条件を満たすものだけに絞り込みます。
+// 絞り込んだ結果が limit 件に満たない間は traQ の続きのページを取得します（最大 maxStampFilterUpstreamCalls 回）。
+// 返却する TotalHits は traQ の値（絞り込み前の件数）のままなので、呼び出し側はレスポンスに含めないこと。
+// 続きを取得するときの traQ の offset（続きが無ければ -1）も返します。
+func (h *Handler) searchTraqMessagesWithStampFilter(c echo.Context, p *traq.MessageSearchParams, filter *stampFilter) (*traq.MessageSearchResult, int, error) {
+	limit := defaultTraqSearchLimit
+	if p.Limit != nil && *p.Limit > 0 {
+		limit = min(*p.Limit, maxImageMessagesLimit)
+	}
+	offset := 0
+	if p.Offset != nil {
+		offset = *p.Offset
+	}
+	// 絞り込みで減る分を見込んで、traQ には上限件数ずつリクエストする
+	pageSize := maxImageMessagesLimit
+
+	q := *p
+	filtered := make([]traq.Message, 0, limit)
+	total := 0
+	for range maxStampFilterUpstreamCalls {
+		pageOffset := offset
+		q.Limit = &pageSize
+		q.Offset = &pageOffset
+		res, err := h.searchTraqMessages(c, &q)
+		if err != nil {
+			// traQからのエラーはそのまま上位へ
+			return nil, 0, err
 		}
-		for _, s := range v.Stamps {
-			if s.StampID == stampID {
-				filtered = append(filtered, raw)
-				break
+		total = res.TotalHits
+
+		for i, m := range res.Hits {
+			if !filter.match(m) {
+				continue
+			}
+			filtered = append(filtered, m)
+			if len(filtered) == limit {
+				// ページの途中で埋まった場合は、次のメッセージから続きを取得する
+				next := pageOffset + i + 1
+				if next >= total {
+					next = -1
+				}
+				return &traq.MessageSearchResult{TotalHits: total, Hits: filtered}, next, nil
 			}
 		}
-	}
 
-	env.Hits = filtered
-	out, err := json.Marshal(env)
-	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("failed to encode filtered messages: %w", err)
+		offset += len(res.Hits)
+		if len(res.Hits) < pageSize || offset >= total {
+			return &traq.MessageSearchResult{TotalHits: total, Hits: filtered}, -1, nil
+		}
 	}
-	return out, status, nil
+
+	// リクエスト回数の上限に達した。続きは次の offset から
+	return &traq.MessageSearchResult{TotalHits: total, Hits: filtered}, offset, nil
 }
 
 // GetLatestMessageByImageID
@@ -376,32 +346,36 @@ func (h *Handler) GetLatestMessageByImageID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "id is required")
 	}
 
-	word := "https://q.trap.jp/files/" + id
-	limit := 1
-	// 最古を取得するため昇順
-	sort := "createdAt"
-	params := &traqMessageSearchParams{
-		Word:  word,
-		Limit: &limit,
-		Sort:  sort,
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
 	}
 
-	body, status, err := h.searchTraqMessages(c, params)
+	m, err := h.findOldestImageMessage(c.Request().Context(), token, id)
 	if err != nil {
-		// traQ 側のエラーはそのまま中継
-		return c.Blob(status, "application/json", body)
+		return traqHTTPError(err, "traQ search failed")
 	}
-
-	// レスポンスから最古1件のhitのみ抽出（昇順ソートの先頭）
-	var resp struct {
-		Hits []json.RawMessage `json:"hits"`
-	}
-	if uerr := json.Unmarshal(body, &resp); uerr != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to parse traQ response").SetInternal(uerr)
-	}
-	if len(resp.Hits) == 0 {
+	if m == nil {
 		return echo.NewHTTPError(http.StatusNotFound, "no message found for the given image id")
 	}
 	// 先頭(最古)のみ返す
-	return c.Blob(http.StatusOK, "application/json", resp.Hits[0])
+	return c.JSON(http.StatusOK, m)
+}
+
+// findOldestImageMessage は画像を含む最古のメッセージを返す。見つからなければ nil。
+func (h *Handler) findOldestImageMessage(ctx context.Context, token, fileID string) (*traq.Message, error) {
+	limit := 1
+	// 最古を取得するため昇順
+	res, err := h.traq.SearchMessages(ctx, token, &traq.MessageSearchParams{
+		Word:  h.traq.FileURL(fileID),
+		Limit: &limit,
+		Sort:  "createdAt",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages for image (id=%s): %w", fileID, err)
+	}
+	if len(res.Hits) == 0 {
+		return nil, nil
+	}
+	return &res.Hits[0], nil
 }
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// doSharedTraq は同じ key の traQ からの取得を1回にまとめて fetch を実行する。
+// まとめた取得は最初に来た呼び出し元のトークンで行うので、そのトークンが失効していて 401/403 になったときは、
+// 待っていた呼び出し元は自分のトークンで取得し直す（他人のトークンのエラーを返さない）。
+// fetch は呼び出し元のトークンで取得する関数で、キャッシュへの保存も fetch の中で行う。
+func doSharedTraq(g *singleflight.Group, key string, fetch func() (interface{}, error)) (interface{}, error) {
+	ran := false
+	v, err, _ := g.Do(key, func() (interface{}, error) {
+		ran = true
+		return fetch()
+	})
+	if err != nil && !ran {
+		if status := traq.StatusCode(err); status == http.StatusUnauthorized || status == http.StatusForbidden {
+			return fetch()
+		}
+	}
+	return v, err
+}
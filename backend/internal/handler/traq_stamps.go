@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// traQのスタンプ一覧をキャッシュする期間（全ユーザーに同じ一覧が見えるため、リクエスト間で共有する）
+	stampCacheTTL = 10 * time.Minute
+
+	stampIndexKey = "all"
+)
+
+var errTraqStampNotFound = errors.New("traQ stamp not found")
+
+// traqStampSummary はスタンプの表示用の情報
+type traqStampSummary struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	FileID    string `json:"fileId"`
+	IsUnicode bool   `json:"isUnicode"`
+}
+
+// stampIndex はスタンプ一覧と名前からIDへの対応
+type stampIndex struct {
+	stamps []traqStampSummary
+	ids    map[string]string // 名前 → ID
+}
+
+func newStampIndex(list []traq.Stamp) *stampIndex {
+	idx := &stampIndex{
+		stamps: make([]traqStampSummary, 0, len(list)),
+		ids:    make(map[string]string, len(list)),
+	}
+	for _, s := range list {
+		idx.stamps = append(idx.stamps, traqStampSummary{
+			ID:        s.ID,
+			Name:      s.Name,
+			FileID:    s.FileID,
+			IsUnicode: s.IsUnicode,
+		})
+		idx.ids[s.Name] = s.ID
+	}
+	return idx
+}
+
+// GetTraqStamps
+// GET /api/v1/traq/stamps
+// スタンプの一覧（ID・名前・画像のファイルID）を返す
+func (h *Handler) GetTraqStamps(c echo.Context) error {
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	idx, err := h.getStampIndex(c.Request().Context(), token)
+	if err != nil {
+		return traqHTTPError(err, "failed to request traQ")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"stamps": idx.stamps,
+	})
+}
+
+// resolveStampName はスタンプ名（":good:" のようにコロンで囲んでもよい）をIDに変換する。
+// 見つからなければ errTraqStampNotFound を返す。
+func (h *Handler) resolveStampName(ctx context.Context, token, name string) (string, error) {
+	idx, err := h.getStampIndex(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	if id, ok := idx.ids[strings.Trim(strings.TrimSpace(name), ":")]; ok {
+		return id, nil
+	}
+	return "", errTraqStampNotFound
+}
+
+// getStampIndex はスタンプ一覧をキャッシュから、無ければtraQから取得する。
+// 同時に取得が必要になった場合は1回にまとめる（doSharedTraq）。
+func (h *Handler) getStampIndex(ctx context.Context, token string) (*stampIndex, error) {
+	if idx, ok := h.stampCache.Get(stampIndexKey); ok {
+		return idx, nil
+	}
+
+	v, err := doSharedTraq(&h.stampGroup, stampIndexKey, func() (interface{}, error) {
+		// 呼び出し元のキャンセルが他の待機中のリクエストに波及しないようにする
+		list, err := h.traq.GetStamps(context.WithoutCancel(ctx), token)
+		if err != nil {
+			return nil, err
+		}
+		idx := newStampIndex(list)
+		h.stampCache.Set(stampIndexKey, idx)
+		return idx, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*stampIndex), nil
+}
+
+// stampFilter はメッセージに付いたスタンプによる絞り込み条件
+type stampFilter struct {
+	stampIDs []string
+	// true なら全てのスタンプが、false ならいずれかのスタンプが付いたメッセージに絞り込む
+	matchAll bool
+	// スタンプごとに必要な個数（全ユーザーの合計）。stampIDs が空ならスタンプの総数に対する条件になる
+	minCount int
+}
+
+// match はメッセージが条件を満たすかを返す
+func (f *stampFilter) match(m traq.Message) bool {
+	counts := make(map[string]int, len(m.Stamps))
+	total := 0
+	for _, s := range m.Stamps {
+		counts[s.StampID] += s.Count
+		total += s.Count
+	}
+
+	minCount := max(f.minCount, 1)
+	if len(f.stampIDs) == 0 {
+		return total >= minCount
+	}
+	for _, id := range f.stampIDs {
+		ok := counts[id] >= minCount
+		if ok && !f.matchAll {
+			return true
+		}
+		if !ok && f.matchAll {
+			return false
+		}
+	}
+	return f.matchAll
+}
+
+// stampFilterFromQuery はクエリからスタンプの絞り込み条件を組み立てる。絞り込まない場合は nil を返す。
+//   - stampId: スタンプID（複数指定可）
+//   - stamp: スタンプ名（複数指定可）
+//   - stampMode: any（既定、いずれかのスタンプ）| all（全てのスタンプ）
+//   - minStampCount: スタンプごとに必要な個数
+func (h *Handler) stampFilterFromQuery(c echo.Context) (*stampFilter, error) {
+	q := c.QueryParams()
+	f := &stampFilter{}
+	seen := make(map[string]struct{})
+	add := func(id string) {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			f.stampIDs = append(f.stampIDs, id)
+		}
+	}
+
+	for _, id := range q["stampId"] {
+		if id != "" {
+			add(id)
+		}
+	}
+	for _, name := range q["stamp"] {
+		if name == "" {
+			continue
+		}
+		token := getSessionToken(c)
+		if token == "" {
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+		}
+		id, err := h.resolveStampName(c.Request().Context(), token, name)
+		if err != nil {
+			if errors.Is(err, errTraqStampNotFound) {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "unknown stamp: "+name)
+			}
+			return nil, traqHTTPError(err, "failed to resolve stamp")
+		}
+		add(id)
+	}
+
+	switch mode := c.QueryParam("stampMode"); mode {
+	case "", "any":
+	case "all":
+		f.matchAll = true
+	default:
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "stampMode must be any or all")
+	}
+	if v := c.QueryParam("minStampCount"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "minStampCount must be a non-negative integer")
+		}
+		f.minCount = n
+	}
+
+	if len(f.stampIDs) == 0 && f.minCount == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
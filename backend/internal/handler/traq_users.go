@@ -1,12 +1,45 @@
 package handler
 
 import (
-	"log"
+	"context"
+	"errors"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
 
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	// traQのユーザー情報をキャッシュする期間（ユーザー情報はtraQの全ユーザーに公開されているため、リクエスト間で共有する）
+	userCacheTTL        = 10 * time.Minute
+	userCacheMaxEntries = 10_000
+	// 一括取得で指定できるIDと名前の合計の上限
+	maxUserBatchSize = 100
+)
+
+var errTraqUserNotFound = errors.New("traQ user not found")
+
+// traqUserProfile はユーザー表示用の最小限の情報
+type traqUserProfile struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	IconFileID  string `json:"iconFileId"`
+}
+
+func newTraqUserProfile(u *traq.UserDetail) traqUserProfile {
+	return traqUserProfile{
+		ID:          u.ID,
+		Name:        u.Name,
+		DisplayName: u.DisplayName,
+		IconFileID:  u.IconFileID,
+	}
+}
+
 // GET /api/v1/traq/users/:id
 // traQ APIのユーザー詳細をプロキシ
 func (h *Handler) GetTraqUserByID(c echo.Context) error {
@@ -15,27 +48,170 @@ func (h *Handler) GetTraqUserByID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "id is required")
 	}
 
-	token := getTokenFromCookie(c)
+	token := getSessionToken(c)
 	if token == "" {
 		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
 	}
 
-	url := "https://q.trap.jp/api/v3/users/" + id
-	req, err := http.NewRequestWithContext(c.Request().Context(), http.MethodGet, url, nil)
+	u, err := h.getTraqUser(c.Request().Context(), token, id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to build request").SetInternal(err)
+		return traqHTTPError(err, "failed to request traQ")
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
 
-	resp, err := h.client.Do(req)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadGateway, "failed to request traQ").SetInternal(err)
+	return c.JSON(http.StatusOK, u)
+}
+
+// PostTraqUsersBatch
+// POST /api/v1/traq/users/batch
+// body: {"ids": [...], "names": [...]} （合計100件まで）
+// 指定したユーザーの表示用プロフィールをまとめて返す。見つからないIDや名前は notFound に入れる。
+func (h *Handler) PostTraqUsersBatch(c echo.Context) error {
+	req := new(struct {
+		IDs   []string `json:"ids"`
+		Names []string `json:"names"`
+	})
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if len(req.IDs)+len(req.Names) > maxUserBatchSize {
+		return echo.NewHTTPError(http.StatusBadRequest, "too many users")
+	}
+
+	token := getSessionToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	ctx := c.Request().Context()
+
+	// 指定順に重複なく並べる（名前は "@name" と区別する）
+	keys := make([]string, 0, len(req.IDs)+len(req.Names))
+	seen := make(map[string]struct{})
+	for _, id := range req.IDs {
+		if id = strings.TrimSpace(id); id != "" {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				keys = append(keys, id)
+			}
+		}
+	}
+	for _, name := range req.Names {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+		if name != "" {
+			if _, ok := seen["@"+name]; !ok {
+				seen["@"+name] = struct{}{}
+				keys = append(keys, "@"+name)
+			}
+		}
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			log.Printf("warn: failed to close response body: %v", cerr)
+
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, maxImageFetchConcurrency)
+		results  = make([]*traq.UserDetail, len(keys))
+		errMu    sync.Mutex
+		firstErr error
+	)
+	for i, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var (
+				u   *traq.UserDetail
+				err error
+			)
+			if name, ok := strings.CutPrefix(key, "@"); ok {
+				u, err = h.getTraqUserByName(ctx, token, name)
+			} else {
+				u, err = h.getTraqUser(ctx, token, key)
+			}
+			if err != nil {
+				if traq.StatusCode(err) == http.StatusNotFound || errors.Is(err, errTraqUserNotFound) {
+					return
+				}
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+				return
+			}
+			results[i] = u
+		}(i, key)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return traqHTTPError(firstErr, "failed to request traQ")
+	}
+
+	users := make([]traqUserProfile, 0, len(keys))
+	notFound := make([]string, 0)
+	returned := make(map[string]struct{}, len(keys))
+	for i, u := range results {
+		if u == nil {
+			notFound = append(notFound, strings.TrimPrefix(keys[i], "@"))
+			continue
+		}
+		// IDと名前で同じユーザーを指定した場合は1件にまとめる
+		if _, ok := returned[u.ID]; ok {
+			continue
+		}
+		returned[u.ID] = struct{}{}
+		users = append(users, newTraqUserProfile(u))
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"users":    users,
+		"notFound": notFound,
+	})
+}
+
+// getTraqUser はユーザー詳細をキャッシュから、無ければtraQから取得する。
+// 同じユーザーへの同時リクエストは1回にまとめる（doSharedTraq）。
+func (h *Handler) getTraqUser(ctx context.Context, token, id string) (*traq.UserDetail, error) {
+	if u, ok := h.userCache.Get(id); ok {
+		return &u, nil
+	}
+
+	v, err := doSharedTraq(&h.userGroup, "id:"+id, func() (interface{}, error) {
+		// 呼び出し元のキャンセルが他の待機中のリクエストに波及しないようにする
+		u, err := h.traq.GetUser(context.WithoutCancel(ctx), token, id)
+		if err != nil {
+			return nil, err
 		}
-	}()
+		h.userCache.Set(u.ID, *u)
+		return u, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	u := *v.(*traq.UserDetail)
+	return &u, nil
+}
 
-	return h.proxyResponse(c, resp)
+// getTraqUserByName は名前からユーザー詳細を取得する。見つからなければ errTraqUserNotFound を返す。
+func (h *Handler) getTraqUserByName(ctx context.Context, token, name string) (*traq.UserDetail, error) {
+	if id, ok := h.userIDsByName.Get(name); ok {
+		return h.getTraqUser(ctx, token, id)
+	}
+
+	v, err := doSharedTraq(&h.userGroup, "name:"+name, func() (interface{}, error) {
+		users, err := h.traq.GetUsersByName(context.WithoutCancel(ctx), token, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			if u.Name == name {
+				h.userIDsByName.Set(name, u.ID)
+				return u.ID, nil
+			}
+		}
+		return nil, errTraqUserNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.getTraqUser(ctx, token, v.(string))
 }
@@ -0,0 +1,301 @@
+// Package indexer は traQ の画像付きメッセージをバックグラウンドでクロールし、ローカルの画像索引を更新します。
+// 索引は GET /api/v1/images?source=local の検索に利用されます。
+// また、直近の投稿を短い間隔で取り込み、ライブフィードに配信します。
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+	"github.com/traP-jp/1m25_10/backend/internal/feed"
+	"github.com/traP-jp/1m25_10/backend/internal/repository"
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// 進捗を保存する名前
+	stateName = "traq-messages"
+	// traQ検索APIの limit の上限
+	pageSize = 100
+	// 同じ after のまま offset を進める上限。これを超えたら after を進めて offset を戻す
+	maxCrawlOffset = 1000
+	// ポーリングで使う公開チャンネルの一覧を取得し直す間隔（クロールのたびにも取得し直す）
+	publicChannelsTTL = 5 * time.Minute
+)
+
+// Config is the configuration of an Indexer.
+type Config struct {
+	// Token is the access token the indexer crawls traQ with (e.g. a bot's token).
+	Token string
+	// Interval is the time between crawls.
+	Interval time.Duration
+	// RecrawlWindow is how far before the newest crawled message each crawl
+	// starts, so that stamps added to recent messages are picked up.
+	RecrawlWindow time.Duration
+	// PollInterval is the time between polls for newly posted messages. Polling is disabled if it is 0.
+	PollInterval time.Duration
+}
+
+// Indexer crawls traQ messages with images into the local image index.
+type Indexer struct {
+	repo repository.IndexRepository
+	traq *traq.Client
+	feed *feed.Hub
+	cfg  Config
+
+	// クロール・ポーリングを同時に実行しないためのロック
+	mu sync.Mutex
+	// 公開チャンネルのID
+	public   map[string]struct{}
+	publicAt time.Time
+	// 次のポーリングで取り込む投稿日時の下限
+	pollAfter time.Time
+}
+
+// New creates an Indexer. Newly posted messages found by polling are published to hub unless it is nil.
+func New(repo repository.IndexRepository, traqClient *traq.Client, hub *feed.Hub, cfg Config) *Indexer {
+	return &Indexer{
+		repo:      repo,
+		traq:      traqClient,
+		feed:      hub,
+		cfg:       cfg,
+		pollAfter: time.Now(),
+	}
+}
+
+// Run crawls immediately and then every Interval, and polls every PollInterval, until ctx is canceled.
+func (ix *Indexer) Run(ctx context.Context) {
+	ticker := time.NewTicker(ix.cfg.Interval)
+	defer ticker.Stop()
+	var poll <-chan time.Time
+	if ix.cfg.PollInterval > 0 {
+		pollTicker := time.NewTicker(ix.cfg.PollInterval)
+		defer pollTicker.Stop()
+		poll = pollTicker.C
+	}
+
+	crawl := func() {
+		n, err := ix.RunOnce(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("warn: image indexer: %v", err)
+			}
+		} else if n > 0 {
+			log.Printf("image indexer: indexed %d messages", n)
+		}
+	}
+
+	crawl()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			crawl()
+		case <-poll:
+			if _, err := ix.Poll(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("warn: image indexer: poll: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce crawls the messages with images posted since the previous crawl
+// (re-crawling RecrawlWindow before it) and returns the number of messages indexed.
+// Only messages in public channels are indexed.
+func (ix *Indexer) RunOnce(ctx context.Context) (int, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	hwm, err := ix.repo.GetIndexerHighWaterMark(ctx, stateName)
+	if err != nil {
+		return 0, err
+	}
+
+	public, err := ix.publicChannels(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+
+	var after time.Time
+	if hwm != nil {
+		after = hwm.Add(-ix.cfg.RecrawlWindow)
+	}
+
+	hasImage := true
+	limit := pageSize
+	indexed := 0
+	offset := 0
+	for {
+		p := &traq.MessageSearchParams{
+			HasImage: &hasImage,
+			Sort:     "createdAt",
+			Limit:    &limit,
+			Offset:   &offset,
+		}
+		if !after.IsZero() {
+			p.After = after.UTC().Format(time.RFC3339Nano)
+		}
+		res, err := ix.traq.SearchMessages(ctx, ix.cfg.Token, p)
+		if err != nil {
+			return indexed, fmt.Errorf("failed to search messages: %w", err)
+		}
+		if len(res.Hits) == 0 {
+			return indexed, nil
+		}
+
+		batch := make([]domain.IndexedMessage, 0, len(res.Hits))
+		for _, m := range res.Hits {
+			if _, ok := public[m.ChannelID]; !ok {
+				continue
+			}
+			if im, ok := ix.indexedMessage(m); ok {
+				batch = append(batch, im)
+			}
+		}
+		if err := ix.repo.SaveIndexedMessages(ctx, batch); err != nil {
+			return indexed, err
+		}
+		indexed += len(batch)
+
+		// 次回はここから再開する（途中で失敗してもそれまでの進捗は残る）
+		last := res.Hits[len(res.Hits)-1].CreatedAt
+		if hwm == nil || last.After(*hwm) {
+			if err := ix.repo.SaveIndexerHighWaterMark(ctx, stateName, last); err != nil {
+				return indexed, err
+			}
+			hwm = &last
+		}
+
+		if len(res.Hits) < pageSize {
+			return indexed, nil
+		}
+		offset += len(res.Hits)
+		if offset >= maxCrawlOffset && last.After(after) {
+			// traQ の offset の上限に近づかないよう、取得済みの最新の投稿日時から数え直す
+			after = last
+			offset = 0
+		}
+	}
+}
+
+// Poll indexes the messages with images posted since the previous poll (or since the Indexer
+// was created) and publishes them to the live feed. It returns the number of messages indexed.
+// Only messages in public channels are indexed.
+func (ix *Indexer) Poll(ctx context.Context) (int, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	public, err := ix.publicChannels(ctx, false)
+	if err != nil {
+		return 0, err
+	}
+
+	hasImage := true
+	limit := pageSize
+	indexed := 0
+	newest := ix.pollAfter
+	for offset := 0; offset < maxCrawlOffset; offset += pageSize {
+		p := &traq.MessageSearchParams{
+			After:    ix.pollAfter.UTC().Format(time.RFC3339Nano),
+			HasImage: &hasImage,
+			Sort:     "createdAt",
+			Limit:    &limit,
+			Offset:   &offset,
+		}
+		res, err := ix.traq.SearchMessages(ctx, ix.cfg.Token, p)
+		if err != nil {
+			return indexed, fmt.Errorf("failed to search messages: %w", err)
+		}
+
+		batch := make([]domain.IndexedMessage, 0, len(res.Hits))
+		for _, m := range res.Hits {
+			if m.CreatedAt.After(newest) {
+				newest = m.CreatedAt
+			}
+			if _, ok := public[m.ChannelID]; !ok {
+				continue
+			}
+			if im, ok := ix.indexedMessage(m); ok {
+				batch = append(batch, im)
+			}
+		}
+		if err := ix.repo.SaveIndexedMessages(ctx, batch); err != nil {
+			return indexed, err
+		}
+		indexed += len(batch)
+		if ix.feed != nil {
+			ix.feed.Publish(batch...)
+		}
+
+		if len(res.Hits) < pageSize {
+			break
+		}
+	}
+	// 同じ after のまま offset を進めて取得したので、全て取り込んでから進める
+	ix.pollAfter = newest
+	return indexed, nil
+}
+
+// publicChannels は公開チャンネルのIDを返す。refresh でなければ一定時間は前回の結果を使う
+func (ix *Indexer) publicChannels(ctx context.Context, refresh bool) (map[string]struct{}, error) {
+	if !refresh && ix.public != nil && time.Since(ix.publicAt) < publicChannelsTTL {
+		return ix.public, nil
+	}
+	channels, err := ix.traq.GetChannels(ctx, ix.cfg.Token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channels: %w", err)
+	}
+	public := make(map[string]struct{}, len(channels.Public))
+	for _, ch := range channels.Public {
+		public[ch.ID] = struct{}{}
+	}
+	ix.public, ix.publicAt = public, time.Now()
+	return public, nil
+}
+
+// indexedMessage は traQ のメッセージを索引の形に変換する。画像やIDが解釈できなければ false を返す。
+func (ix *Indexer) indexedMessage(m traq.Message) (domain.IndexedMessage, bool) {
+	id, err1 := uuid.Parse(m.ID)
+	channelID, err2 := uuid.Parse(m.ChannelID)
+	userID, err3 := uuid.Parse(m.UserID)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return domain.IndexedMessage{}, false
+	}
+
+	images := make([]uuid.UUID, 0)
+	for _, s := range ix.traq.ExtractFileIDs(m.Content) {
+		if fileID, err := uuid.Parse(s); err == nil {
+			images = append(images, fileID)
+		}
+	}
+	if len(images) == 0 {
+		return domain.IndexedMessage{}, false
+	}
+
+	stamps := make([]domain.IndexedStamp, 0, len(m.Stamps))
+	for _, s := range m.Stamps {
+		stampID, err1 := uuid.Parse(s.StampID)
+		userID, err2 := uuid.Parse(s.UserID)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		stamps = append(stamps, domain.IndexedStamp{StampID: stampID, UserID: userID, Count: s.Count})
+	}
+
+	return domain.IndexedMessage{
+		Id:        id,
+		ChannelID: channelID,
+		UserID:    userID,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+		Images:    images,
+		Stamps:    stamps,
+	}, true
+}
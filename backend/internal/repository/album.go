@@ -250,17 +250,34 @@ func (r *sqlRepositoryImpl) GetAlbum(ctx context.Context, albumID uuid.UUID) (*d
 }
 
 // DeleteAlbum deletes an album by its ID
-func (r *sqlRepositoryImpl) DeleteAlbum(ctx context.Context, albumID uuid.UUID) error {
+func (r *sqlRepositoryImpl) DeleteAlbum(ctx context.Context, albumID uuid.UUID) (err error) {
 	if albumID == uuid.Nil {
 		return fmt.Errorf("invalid album id")
 	}
 
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// album_images は外部キーで albums を参照しているので先に削除する
+	delQuery := `DELETE FROM album_images WHERE album_id = ?`
+	delQuery = tx.Rebind(delQuery)
+	if _, err = tx.ExecContext(ctx, delQuery, albumID); err != nil {
+		return fmt.Errorf("failed to delete album images (album_id=%s) : %w", albumID, err)
+	}
+
 	query := `
 		DELETE FROM albums
 		WHERE id = ?
 	`
-	query = r.db.Rebind(query)
-	result, err := r.db.ExecContext(ctx, query, albumID)
+	query = tx.Rebind(query)
+	result, err := tx.ExecContext(ctx, query, albumID)
 	if err != nil {
 		return fmt.Errorf("failed to delete album (id=%s) : %w", albumID, err)
 	}
@@ -270,16 +287,25 @@ func (r *sqlRepositoryImpl) DeleteAlbum(ctx context.Context, albumID uuid.UUID)
 		return fmt.Errorf("failed to get rows affected (id=%s) : %w", albumID, err)
 	}
 	if ra == 0 {
-		return ErrNotFound
+		err = ErrNotFound
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit album deletion (id=%s): %w", albumID, err)
 	}
 	return nil
 }
 
 // UpdateAlbum updates an album with the given parameters
-func (r *sqlRepositoryImpl) UpdateAlbum(ctx context.Context, albumID uuid.UUID, params domain.UpdateAlbumParams) error {
+func (r *sqlRepositoryImpl) UpdateAlbum(ctx context.Context, albumID uuid.UUID, params domain.UpdateAlbumParams) (err error) {
 	if albumID == uuid.Nil {
 		return fmt.Errorf("invalid album id")
 	}
+	// 画像だけの更新もできる
+	if params.Title == nil && params.Description == nil && params.Images == nil {
+		return domain.ErrNoFieldsToUpdate
+	}
 
 	sets := []string{}
 	args := []interface{}{}
@@ -292,68 +318,72 @@ func (r *sqlRepositoryImpl) UpdateAlbum(ctx context.Context, albumID uuid.UUID,
 		sets = append(sets, "description = ?")
 		args = append(args, *params.Description)
 	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// UPDATE の RowsAffected は値が変わらなかった行を数えない（updated_at は秒単位）ので、存在は先に確認する
+	var count int
+	if err = tx.GetContext(ctx, &count, tx.Rebind(`SELECT COUNT(*) FROM albums WHERE id = ?`), albumID); err != nil {
+		return fmt.Errorf("failed to get album (id=%s): %w", albumID, err)
+	}
+	if count == 0 {
+		err = ErrNotFound
+		return err
+	}
+
 	if params.Images != nil {
 		// Imageは差分更新できるようにしてもいいかもしれない
 		// 以下のコードは全て置き換える実装
 		// 既存の関係を削除
 		delQuery := `DELETE FROM album_images WHERE album_id = ?`
-		delQuery = r.db.Rebind(delQuery)
-		_, err := r.db.ExecContext(ctx, delQuery, albumID)
-		if err != nil {
+		delQuery = tx.Rebind(delQuery)
+		if _, err = tx.ExecContext(ctx, delQuery, albumID); err != nil {
 			return fmt.Errorf("failed to delete existing album images (album_id=%s): %w", albumID, err)
 		}
 
-		// 新しい関係を挿入
+		// 新しい関係を挿入（未登録の画像は images にも追加する）
+		imgQuery := `INSERT INTO images (id) VALUES (?) ON DUPLICATE KEY UPDATE id = id`
 		insQuery := `
 			INSERT INTO album_images (id, album_id, image_id)
 			VALUES (:id, :album_id, :image_id)
 		`
 		for _, imgID := range *params.Images {
-			_, err := r.GetImage(ctx, imgID)
-			if err != nil {
-				if errors.Is(err, ErrNotFound) {
-					_, err = r.PostImage(ctx, imgID)
-					if err != nil {
-						return fmt.Errorf("failed to post new image (image_id=%s): %w", imgID, err)
-					}
-				} else {
-					return fmt.Errorf("failed to get image (image_id=%s): %w", imgID, err)
-				}
+			if _, err = tx.ExecContext(ctx, tx.Rebind(imgQuery), imgID); err != nil {
+				return fmt.Errorf("failed to post new image (image_id=%s): %w", imgID, err)
 			}
 			newAlbumImage := AlbumImage{
 				Id:      uuid.New(),
 				AlbumID: albumID,
 				ImageID: imgID,
 			}
-			_, err = r.db.NamedExecContext(ctx, insQuery, newAlbumImage)
-			if err != nil {
+			if _, err = tx.NamedExecContext(ctx, insQuery, newAlbumImage); err != nil {
 				return fmt.Errorf("failed to insert new album image (album_id=%s, image_id=%s): %w", albumID, imgID, err)
 			}
 		}
 	}
 
-	if len(sets) == 0 {
-		return domain.ErrNoFieldsToUpdate
-	}
-
 	sets = append(sets, "updated_at = ?")
 	args = append(args, time.Now())
 
 	args = append(args, albumID)
 
 	query := "UPDATE albums SET " + strings.Join(sets, ", ") + " WHERE id = ?"
-	query = r.db.Rebind(query)
+	query = tx.Rebind(query)
 
-	result, err := r.db.ExecContext(ctx, query, args...)
-	if err != nil {
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
 		return fmt.Errorf("failed to update album (id=%s): %w", albumID, err)
 	}
-	ra, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("rows affected error (id=%s): %w", albumID, err)
-	}
-	if ra == 0 {
-		return ErrNotFound
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit album update (id=%s): %w", albumID, err)
 	}
 	return nil
 }
@@ -5,13 +5,34 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
 )
 
 type ImageRepository interface {
 	PostImage(ctx context.Context, ImageID uuid.UUID) (*uuid.UUID, error)
 	GetImage(ctx context.Context, imageID uuid.UUID) (*uuid.UUID, error)
+	GetImages(ctx context.Context, imageIDs []uuid.UUID) ([]domain.Image, error)
+	SaveImageAnalysis(ctx context.Context, imageID uuid.UUID, analysis domain.ImageAnalysis) error
+	SearchImages(ctx context.Context, filter domain.ImageSearchFilter) (int, []uuid.UUID, error)
+	SaveImageMetadata(ctx context.Context, imageID uuid.UUID, metadata domain.ImageMetadata) error
+}
+
+type dbImage struct {
+	Id                  uuid.UUID  `db:"id"`
+	PHash               *uint64    `db:"phash"`
+	Blurhash            *string    `db:"blurhash"`
+	DominantColor       *string    `db:"dominant_color"`
+	AnalysisVersion     int        `db:"analysis_version"`
+	TakenAt             *time.Time `db:"taken_at"`
+	CameraModel         *string    `db:"camera_model"`
+	Orientation         *int       `db:"orientation"`
+	PostedAt            *time.Time `db:"posted_at"`
+	MetadataExtractedAt *time.Time `db:"metadata_extracted_at"`
 }
 
 // PostImage stores a new image in the database.
@@ -39,3 +60,204 @@ func (r *sqlRepositoryImpl) GetImage(ctx context.Context, imageID uuid.UUID) (*u
 
 	return &id, nil
 }
+
+// GetImages retrieves the images with the given IDs.
+// IDs that are not stored yet are silently omitted from the result.
+func (r *sqlRepositoryImpl) GetImages(ctx context.Context, imageIDs []uuid.UUID) ([]domain.Image, error) {
+	if len(imageIDs) == 0 {
+		return []domain.Image{}, nil
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT id, phash, blurhash, dominant_color, analysis_version, taken_at, camera_model, orientation, posted_at, metadata_extracted_at
+		FROM images
+		WHERE id IN (?)
+	`, imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query with sqlx.In: %w", err)
+	}
+	query = r.db.Rebind(query)
+
+	var dbImages []dbImage
+	if err := r.db.SelectContext(ctx, &dbImages, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to select images: %w", err)
+	}
+
+	images := make([]domain.Image, 0, len(dbImages))
+	for _, img := range dbImages {
+		images = append(images, domain.Image{
+			Id:                  img.Id,
+			PHash:               img.PHash,
+			Blurhash:            img.Blurhash,
+			DominantColor:       img.DominantColor,
+			AnalysisVersion:     img.AnalysisVersion,
+			TakenAt:             img.TakenAt,
+			CameraModel:         img.CameraModel,
+			Orientation:         img.Orientation,
+			PostedAt:            img.PostedAt,
+			MetadataExtractedAt: img.MetadataExtractedAt,
+		})
+	}
+	return images, nil
+}
+
+// SaveImageAnalysis stores the values computed from the thumbnail of an image
+// (replacing its color palette), creating the image if needed.
+func (r *sqlRepositoryImpl) SaveImageAnalysis(ctx context.Context, imageID uuid.UUID, analysis domain.ImageAnalysis) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO images (id, phash, blurhash, dominant_color, analysis_version) VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			phash = VALUES(phash),
+			blurhash = VALUES(blurhash),
+			dominant_color = VALUES(dominant_color),
+			analysis_version = VALUES(analysis_version)
+	`
+	_, err = tx.ExecContext(ctx, query, imageID, analysis.PHash, analysis.Blurhash, analysis.DominantColor, analysis.Version)
+	if err != nil {
+		return fmt.Errorf("failed to save image analysis (id=%s): %w", imageID, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM image_colors WHERE image_id = ?`, imageID); err != nil {
+		return fmt.Errorf("failed to delete image colors (id=%s): %w", imageID, err)
+	}
+	for i, pc := range analysis.Palette {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO image_colors (image_id, `rank`, l, a, b, weight) VALUES (?, ?, ?, ?, ?, ?)",
+			imageID, i, pc.Color.L, pc.Color.A, pc.Color.B, pc.Weight)
+		if err != nil {
+			return fmt.Errorf("failed to insert image color (id=%s): %w", imageID, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit image analysis (id=%s): %w", imageID, err)
+	}
+	return nil
+}
+
+// SaveImageMetadata stores the EXIF and source message metadata of an image, creating the image if needed.
+func (r *sqlRepositoryImpl) SaveImageMetadata(ctx context.Context, imageID uuid.UUID, metadata domain.ImageMetadata) error {
+	query := `
+		INSERT INTO images (id, taken_at, camera_model, orientation, posted_at, metadata_extracted_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			taken_at = VALUES(taken_at),
+			camera_model = VALUES(camera_model),
+			orientation = VALUES(orientation),
+			posted_at = VALUES(posted_at),
+			metadata_extracted_at = VALUES(metadata_extracted_at)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		imageID, metadata.TakenAt, metadata.CameraModel, metadata.Orientation, metadata.PostedAt, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save image metadata (id=%s): %w", imageID, err)
+	}
+	return nil
+}
+
+// 検索時に無視する、画像に占める割合の小さい色
+const minColorWeight = 0.05
+
+// SearchImages searches images known to the app and returns the total number of matches and a page of image IDs.
+// When filtering by color, images are ordered by how close their closest main color is.
+func (r *sqlRepositoryImpl) SearchImages(ctx context.Context, filter domain.ImageSearchFilter) (int, []uuid.UUID, error) {
+	from := ` FROM images i`
+	where := ` WHERE 1=1`
+	order := ` ORDER BY COALESCE(i.taken_at, i.posted_at) DESC, i.id`
+	args := []interface{}{}
+
+	if filter.Color != nil {
+		from += `
+			JOIN (
+				SELECT image_id, MIN(SQRT(POW(l - ?, 2) + POW(a - ?, 2) + POW(b - ?, 2))) AS distance
+				FROM image_colors
+				WHERE weight >= ?
+				GROUP BY image_id
+			) c ON c.image_id = i.id`
+		args = append(args, filter.Color.L, filter.Color.A, filter.Color.B, minColorWeight)
+		where += ` AND c.distance <= ?`
+		args = append(args, filter.Tolerance)
+		order = ` ORDER BY c.distance, i.id`
+	}
+
+	if filter.HasMessageFilter() {
+		cond, condArgs := indexedMessageCondition(filter)
+		where += ` AND EXISTS (
+			SELECT 1 FROM indexed_message_images mi
+			JOIN indexed_messages m ON m.id = mi.message_id
+			WHERE mi.image_id = i.id` + cond + `)`
+		args = append(args, condArgs...)
+	}
+
+	var total int
+	countQuery := r.db.Rebind(`SELECT COUNT(*)` + from + where)
+	if err := r.db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return 0, nil, fmt.Errorf("failed to count images: %w", err)
+	}
+
+	query := r.db.Rebind(`SELECT i.id` + from + where + order + ` LIMIT ? OFFSET ?`)
+	args = append(args, filter.Limit, filter.Offset)
+
+	ids := []uuid.UUID{}
+	if err := r.db.SelectContext(ctx, &ids, query, args...); err != nil {
+		return 0, nil, fmt.Errorf("failed to search images: %w", err)
+	}
+	return total, ids, nil
+}
+
+// indexedMessageCondition builds the conditions on an indexed message m for the message filters.
+func indexedMessageCondition(filter domain.ImageSearchFilter) (string, []interface{}) {
+	cond := ""
+	args := []interface{}{}
+
+	if filter.ChannelID != nil {
+		cond += ` AND m.channel_id = ?`
+		args = append(args, *filter.ChannelID)
+	}
+	if len(filter.UserIDs) > 0 {
+		cond += ` AND m.user_id IN (?` + strings.Repeat(`, ?`, len(filter.UserIDs)-1) + `)`
+		for _, id := range filter.UserIDs {
+			args = append(args, id)
+		}
+	}
+	if filter.After != nil {
+		cond += ` AND m.created_at > ?`
+		args = append(args, *filter.After)
+	}
+	if filter.Before != nil {
+		cond += ` AND m.created_at < ?`
+		args = append(args, *filter.Before)
+	}
+
+	minCount := max(filter.MinStampCount, 1)
+	if len(filter.StampIDs) == 0 {
+		if filter.MinStampCount > 0 {
+			cond += ` AND m.stamp_count >= ?`
+			args = append(args, filter.MinStampCount)
+		}
+		return cond, args
+	}
+
+	op := ` OR `
+	if filter.StampMatchAll {
+		op = ` AND `
+	}
+	stampConds := make([]string, 0, len(filter.StampIDs))
+	for _, id := range filter.StampIDs {
+		stampConds = append(stampConds,
+			`(SELECT COALESCE(SUM(s.count), 0) FROM indexed_message_stamps s WHERE s.message_id = m.id AND s.stamp_id = ?) >= ?`)
+		args = append(args, id, minCount)
+	}
+	cond += ` AND (` + strings.Join(stampConds, op) + `)`
+	return cond, args
+}
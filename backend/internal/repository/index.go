@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+)
+
+type IndexRepository interface {
+	SaveIndexedMessages(ctx context.Context, messages []domain.IndexedMessage) error
+	UpdateIndexedMessageStamps(ctx context.Context, messageID uuid.UUID, stamps []domain.IndexedStamp) error
+	DeleteIndexedMessage(ctx context.Context, messageID uuid.UUID) error
+	GetIndexerHighWaterMark(ctx context.Context, name string) (*time.Time, error)
+	SaveIndexerHighWaterMark(ctx context.Context, name string, t time.Time) error
+}
+
+// SaveIndexedMessages stores messages in the local image index, replacing their images and stamps.
+// The images are created if needed, keeping the earliest message time as their posted_at.
+func (r *sqlRepositoryImpl) SaveIndexedMessages(ctx context.Context, messages []domain.IndexedMessage) (err error) {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, m := range messages {
+		stampCount := 0
+		for _, s := range m.Stamps {
+			stampCount += s.Count
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO indexed_messages (id, channel_id, user_id, created_at, updated_at, stamp_count)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				channel_id = VALUES(channel_id),
+				user_id = VALUES(user_id),
+				created_at = VALUES(created_at),
+				updated_at = VALUES(updated_at),
+				stamp_count = VALUES(stamp_count)
+		`, m.Id, m.ChannelID, m.UserID, m.CreatedAt, m.UpdatedAt, stampCount)
+		if err != nil {
+			return fmt.Errorf("failed to save indexed message (id=%s): %w", m.Id, err)
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM indexed_message_images WHERE message_id = ?`, m.Id); err != nil {
+			return fmt.Errorf("failed to delete indexed message images (id=%s): %w", m.Id, err)
+		}
+		seen := make(map[uuid.UUID]struct{}, len(m.Images))
+		for i, imageID := range m.Images {
+			if _, ok := seen[imageID]; ok {
+				continue
+			}
+			seen[imageID] = struct{}{}
+
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO images (id, posted_at) VALUES (?, ?)
+				ON DUPLICATE KEY UPDATE posted_at = LEAST(COALESCE(posted_at, VALUES(posted_at)), VALUES(posted_at))
+			`, imageID, m.CreatedAt)
+			if err != nil {
+				return fmt.Errorf("failed to save image (id=%s): %w", imageID, err)
+			}
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO indexed_message_images (message_id, image_id, position) VALUES (?, ?, ?)`,
+				m.Id, imageID, i)
+			if err != nil {
+				return fmt.Errorf("failed to insert indexed message image (id=%s): %w", m.Id, err)
+			}
+		}
+
+		if err = replaceIndexedMessageStamps(ctx, tx, m.Id, m.Stamps); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit indexed messages: %w", err)
+	}
+	return nil
+}
+
+// UpdateIndexedMessageStamps replaces the stamps of an indexed message.
+// It returns ErrNotFound if the message is not indexed.
+func (r *sqlRepositoryImpl) UpdateIndexedMessageStamps(ctx context.Context, messageID uuid.UUID, stamps []domain.IndexedStamp) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stampCount := 0
+	for _, s := range stamps {
+		stampCount += s.Count
+	}
+	// UPDATE の RowsAffected は件数が変わらなかったときに 0 になるので、存在は先に確認する
+	var count int
+	if err = tx.GetContext(ctx, &count, `SELECT COUNT(*) FROM indexed_messages WHERE id = ?`, messageID); err != nil {
+		return fmt.Errorf("failed to get indexed message (id=%s): %w", messageID, err)
+	}
+	if count == 0 {
+		err = ErrNotFound
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE indexed_messages SET stamp_count = ? WHERE id = ?`, stampCount, messageID); err != nil {
+		return fmt.Errorf("failed to update indexed message (id=%s): %w", messageID, err)
+	}
+
+	if err = replaceIndexedMessageStamps(ctx, tx, messageID, stamps); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit indexed message stamps (id=%s): %w", messageID, err)
+	}
+	return nil
+}
+
+func replaceIndexedMessageStamps(ctx context.Context, tx *sqlx.Tx, messageID uuid.UUID, stamps []domain.IndexedStamp) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM indexed_message_stamps WHERE message_id = ?`, messageID); err != nil {
+		return fmt.Errorf("failed to delete indexed message stamps (id=%s): %w", messageID, err)
+	}
+	for _, s := range stamps {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO indexed_message_stamps (message_id, stamp_id, user_id, count) VALUES (?, ?, ?, ?)`,
+			messageID, s.StampID, s.UserID, s.Count)
+		if err != nil {
+			return fmt.Errorf("failed to insert indexed message stamp (id=%s): %w", messageID, err)
+		}
+	}
+	return nil
+}
+
+// DeleteIndexedMessage removes a message from the local image index. Its images are kept.
+// Deleting a message that is not indexed is not an error.
+func (r *sqlRepositoryImpl) DeleteIndexedMessage(ctx context.Context, messageID uuid.UUID) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, query := range []string{
+		`DELETE FROM indexed_message_stamps WHERE message_id = ?`,
+		`DELETE FROM indexed_message_images WHERE message_id = ?`,
+		`DELETE FROM indexed_messages WHERE id = ?`,
+	} {
+		if _, err = tx.ExecContext(ctx, query, messageID); err != nil {
+			return fmt.Errorf("failed to delete indexed message (id=%s): %w", messageID, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit deleting indexed message (id=%s): %w", messageID, err)
+	}
+	return nil
+}
+
+// GetIndexerHighWaterMark returns the creation time of the newest message the named indexer has crawled,
+// or nil if it has not crawled anything yet.
+func (r *sqlRepositoryImpl) GetIndexerHighWaterMark(ctx context.Context, name string) (*time.Time, error) {
+	var t sql.NullTime
+	err := r.db.QueryRowContext(ctx, `SELECT high_water_mark FROM indexer_state WHERE name = ?`, name).Scan(&t)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get indexer state (name=%s): %w", name, err)
+	}
+	if !t.Valid {
+		return nil, nil
+	}
+	return &t.Time, nil
+}
+
+// SaveIndexerHighWaterMark records the creation time of the newest message the named indexer has crawled.
+func (r *sqlRepositoryImpl) SaveIndexerHighWaterMark(ctx context.Context, name string, t time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO indexer_state (name, high_water_mark, updated_at) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			high_water_mark = VALUES(high_water_mark),
+			updated_at = VALUES(updated_at)
+	`, name, t, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save indexer state (name=%s): %w", name, err)
+	}
+	return nil
+}
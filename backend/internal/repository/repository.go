@@ -7,6 +7,8 @@ import (
 type Repository interface {
 	AlbumRepository
 	ImageRepository
+	IndexRepository
+	SessionRepository
 }
 
 type sqlRepositoryImpl struct {
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+)
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, s domain.StoredSession) error
+	GetSessionBySecretHash(ctx context.Context, secretHash string, now time.Time) (*domain.StoredSession, error)
+	GetUserSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]domain.Session, error)
+	DeleteSession(ctx context.Context, id uuid.UUID) error
+	DeleteUserSession(ctx context.Context, userID, id uuid.UUID) error
+	DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error)
+}
+
+type sessionRow struct {
+	Id             uuid.UUID `db:"id"`
+	SecretHash     string    `db:"secret_hash"`
+	UserID         uuid.UUID `db:"user_id"`
+	EncryptedToken []byte    `db:"encrypted_token"`
+	UserAgent      string    `db:"user_agent"`
+	CreatedAt      time.Time `db:"created_at"`
+	ExpiresAt      time.Time `db:"expires_at"`
+}
+
+func (r sessionRow) session() domain.Session {
+	return domain.Session{
+		Id:        r.Id,
+		UserID:    r.UserID,
+		UserAgent: r.UserAgent,
+		CreatedAt: r.CreatedAt,
+		ExpiresAt: r.ExpiresAt,
+	}
+}
+
+// CreateSession stores a new session.
+func (r *sqlRepositoryImpl) CreateSession(ctx context.Context, s domain.StoredSession) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO sessions (id, secret_hash, user_id, encrypted_token, user_agent, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, s.Id, s.SecretHash, s.UserID, s.EncryptedToken, s.UserAgent, s.CreatedAt, s.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session (id=%s): %w", s.Id, err)
+	}
+	return nil
+}
+
+// GetSessionBySecretHash returns the session whose cookie secret hashes to secretHash.
+// It returns ErrNotFound if there is no such session or it expired before now.
+func (r *sqlRepositoryImpl) GetSessionBySecretHash(ctx context.Context, secretHash string, now time.Time) (*domain.StoredSession, error) {
+	var row sessionRow
+	err := r.db.GetContext(ctx, &row, `
+		SELECT id, secret_hash, user_id, encrypted_token, user_agent, created_at, expires_at
+		FROM sessions WHERE secret_hash = ? AND expires_at > ?
+	`, secretHash, now)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return &domain.StoredSession{
+		Session:        row.session(),
+		SecretHash:     row.SecretHash,
+		EncryptedToken: row.EncryptedToken,
+	}, nil
+}
+
+// GetUserSessions returns the sessions of a user that have not expired, newest first.
+func (r *sqlRepositoryImpl) GetUserSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]domain.Session, error) {
+	var rows []sessionRow
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT id, secret_hash, user_id, encrypted_token, user_agent, created_at, expires_at
+		FROM sessions WHERE user_id = ? AND expires_at > ?
+		ORDER BY created_at DESC
+	`, userID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions (user_id=%s): %w", userID, err)
+	}
+	sessions := make([]domain.Session, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, row.session())
+	}
+	return sessions, nil
+}
+
+// DeleteSession removes a session. Deleting a session that does not exist is not an error.
+func (r *sqlRepositoryImpl) DeleteSession(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete session (id=%s): %w", id, err)
+	}
+	return nil
+}
+
+// DeleteUserSession removes a session of a user.
+// It returns ErrNotFound if the user has no such session.
+func (r *sqlRepositoryImpl) DeleteUserSession(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete session (id=%s): %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteExpiredSessions removes the sessions that expired before now and returns how many were removed.
+func (r *sqlRepositoryImpl) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= ?`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,194 @@
+// Package session はログインセッションを管理します。
+// ブラウザの Cookie にはランダムな秘密の値だけを置き、traQ のアクセストークンは AES-GCM で暗号化してデータベースに保存します。
+// データベースには Cookie の値そのものではなくそのハッシュを保存するため、データベースが漏れてもセッションを乗っ取られません。
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/internal/domain"
+	"github.com/traP-jp/1m25_10/backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Cookie に置く秘密の値のバイト数
+	secretBytes = 32
+	// 保存する User-Agent の長さの上限（sessions.user_agent の長さ）
+	maxUserAgentLength = 512
+)
+
+// KeySize is the size of the key used to encrypt access tokens (AES-256).
+const KeySize = 32
+
+// ErrNotFound is returned when a session does not exist, has expired or has been revoked.
+var ErrNotFound = errors.New("session not found")
+
+// Store creates and looks up sessions. It is safe for concurrent use.
+type Store struct {
+	repo repository.SessionRepository
+	aead cipher.AEAD
+	now  func() time.Time
+}
+
+// NewStore creates a Store that encrypts access tokens with key (KeySize bytes).
+func NewStore(repo repository.SessionRepository, key []byte) (*Store, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("session key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return &Store{repo: repo, aead: aead, now: time.Now}, nil
+}
+
+// ParseKey decodes a key given as base64 (standard or URL encoding, with or without padding) or hex.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, dec := range []func(string) ([]byte, error){
+		hex.DecodeString,
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		base64.URLEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+	} {
+		if key, err := dec(s); err == nil && len(key) == KeySize {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("session key must be %d bytes encoded in base64 or hex", KeySize)
+}
+
+// GenerateKey returns a random key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Create starts a session of the user who owns token, valid until expiresAt.
+// It returns the secret to put in the session cookie.
+func (s *Store) Create(ctx context.Context, token string, userID uuid.UUID, userAgent string, expiresAt time.Time) (string, *domain.Session, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, fmt.Errorf("failed to generate session secret: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	if r := []rune(userAgent); len(r) > maxUserAgentLength {
+		userAgent = string(r[:maxUserAgentLength])
+	}
+
+	now := s.now()
+	stored := domain.StoredSession{
+		Session: domain.Session{
+			Id:        id,
+			UserID:    userID,
+			UserAgent: userAgent,
+			CreatedAt: now,
+			ExpiresAt: expiresAt,
+		},
+		SecretHash:     hashSecret(secret),
+		EncryptedToken: s.seal(id, token),
+	}
+	if err := s.repo.CreateSession(ctx, stored); err != nil {
+		return "", nil, err
+	}
+	return secret, &stored.Session, nil
+}
+
+// Lookup returns the session for the secret in a session cookie and its decrypted access token.
+// It returns ErrNotFound if the session does not exist or has expired.
+func (s *Store) Lookup(ctx context.Context, secret string) (*domain.Session, string, error) {
+	if secret == "" {
+		return nil, "", ErrNotFound
+	}
+	stored, err := s.repo.GetSessionBySecretHash(ctx, hashSecret(secret), s.now())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", err
+	}
+	token, err := s.open(stored.Id, stored.EncryptedToken)
+	if err != nil {
+		// 鍵が変わった（再起動で鍵を生成し直したなど）セッションは使えない
+		return nil, "", ErrNotFound
+	}
+	return &stored.Session, token, nil
+}
+
+// List returns the sessions of a user that have not expired, newest first.
+func (s *Store) List(ctx context.Context, userID uuid.UUID) ([]domain.Session, error) {
+	return s.repo.GetUserSessions(ctx, userID, s.now())
+}
+
+// Delete ends a session.
+func (s *Store) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.repo.DeleteSession(ctx, id)
+}
+
+// Revoke ends a session of a user. It returns ErrNotFound if the user has no such session.
+func (s *Store) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	if err := s.repo.DeleteUserSession(ctx, userID, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteExpired removes the expired sessions and returns how many were removed.
+func (s *Store) DeleteExpired(ctx context.Context) (int64, error) {
+	return s.repo.DeleteExpiredSessions(ctx, s.now())
+}
+
+// seal はトークンを暗号化する。暗号文を別のセッションの行に移して使えないよう、セッションIDを追加データにする
+func (s *Store) seal(id uuid.UUID, token string) []byte {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		// crypto/rand.Read は失敗しない
+		panic(err)
+	}
+	return s.aead.Seal(nonce, nonce, []byte(token), id[:])
+}
+
+func (s *Store) open(id uuid.UUID, sealed []byte) (string, error) {
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return "", errors.New("encrypted token is too short")
+	}
+	plain, err := s.aead.Open(nil, sealed[:n], sealed[n:], id[:])
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
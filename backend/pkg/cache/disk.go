@@ -0,0 +1,367 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DiskMeta describes a cached entry. The HTTP validators are kept so that
+// the entry can be revalidated against its origin.
+type DiskMeta struct {
+	Key          string    `json:"key"`
+	Hash         string    `json:"hash"` // SHA-256 of the content
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"contentType"`
+	CacheControl string    `json:"cacheControl"`
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"lastModified"`
+	ValidatedAt  time.Time `json:"validatedAt"`
+}
+
+// DiskStats is a snapshot of the cache usage.
+type DiskStats struct {
+	Entries   int   `json:"entries"`
+	Size      int64 `json:"sizeBytes"`
+	MaxSize   int64 `json:"maxSizeBytes"`
+	Evictions int64 `json:"evictions"`
+}
+
+// Disk is a content-addressed on-disk cache with least-recently-used
+// eviction. Contents are stored once per SHA-256 hash under dir/blobs, and
+// the metadata of each key under dir/meta, so the cache survives restarts.
+// It is safe for concurrent use.
+type Disk struct {
+	dir          string
+	maxSize      int64
+	maxEntrySize int64
+
+	mu        sync.Mutex
+	size      int64 // total size of the blobs
+	ll        *list.List
+	entries   map[string]*list.Element
+	blobRefs  map[string]int
+	evictions int64
+}
+
+// NewDisk opens (or creates) a cache in dir holding at most maxSize bytes.
+// Entries larger than 1/8 of maxSize are not stored.
+func NewDisk(dir string, maxSize int64) (*Disk, error) {
+	d := &Disk{
+		dir:          dir,
+		maxSize:      maxSize,
+		maxEntrySize: maxSize / 8,
+		ll:           list.New(),
+		entries:      make(map[string]*list.Element),
+		blobRefs:     make(map[string]int),
+	}
+	for _, sub := range []string{"blobs", "meta", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// load rebuilds the index from the metadata files, oldest validation first,
+// and removes leftovers of interrupted writes and unreferenced blobs.
+func (d *Disk) load() error {
+	if err := os.RemoveAll(filepath.Join(d.dir, "tmp")); err != nil {
+		return fmt.Errorf("failed to clean cache tmp directory: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(d.dir, "tmp"), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	files, err := os.ReadDir(filepath.Join(d.dir, "meta"))
+	if err != nil {
+		return fmt.Errorf("failed to read cache metadata: %w", err)
+	}
+	metas := make([]DiskMeta, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(d.dir, "meta", f.Name())
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read cache metadata: %w", err)
+		}
+		var m DiskMeta
+		if err := json.Unmarshal(b, &m); err != nil || m.Key == "" {
+			_ = os.Remove(path)
+			continue
+		}
+		if fi, err := os.Stat(d.blobPath(m.Hash)); err != nil || fi.Size() != m.Size {
+			_ = os.Remove(path)
+			continue
+		}
+		metas = append(metas, m)
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].ValidatedAt.Before(metas[j].ValidatedAt) })
+	for _, m := range metas {
+		d.insert(m)
+	}
+
+	err = filepath.WalkDir(filepath.Join(d.dir, "blobs"), func(path string, e os.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		if _, ok := d.blobRefs[e.Name()]; !ok {
+			_ = os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan cache blobs: %w", err)
+	}
+
+	d.evict()
+	return nil
+}
+
+// Open returns the content and metadata for key and marks it as recently used.
+// The caller must close the file. An evicted entry stays readable through
+// files opened before the eviction.
+func (d *Disk) Open(key string) (*os.File, DiskMeta, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	el, ok := d.entries[key]
+	if !ok {
+		return nil, DiskMeta{}, false
+	}
+	m := el.Value.(DiskMeta)
+	f, err := os.Open(d.blobPath(m.Hash))
+	if err != nil {
+		log.Printf("warn: cached blob is missing (key=%s): %v", key, err)
+		d.removeElement(el)
+		return nil, DiskMeta{}, false
+	}
+	d.ll.MoveToFront(el)
+	return f, m, true
+}
+
+// MarkValidated records that the entry for key has been revalidated with its origin at t.
+func (d *Disk) MarkValidated(key string, t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	el, ok := d.entries[key]
+	if !ok {
+		return
+	}
+	m := el.Value.(DiskMeta)
+	m.ValidatedAt = t
+	el.Value = m
+	if err := d.writeMeta(m); err != nil {
+		log.Printf("warn: %v", err)
+	}
+}
+
+// Remove deletes key from the cache.
+func (d *Disk) Remove(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if el, ok := d.entries[key]; ok {
+		d.removeElement(el)
+	}
+}
+
+// Stats returns the current usage of the cache.
+func (d *Disk) Stats() DiskStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return DiskStats{
+		Entries:   len(d.entries),
+		Size:      d.size,
+		MaxSize:   d.maxSize,
+		Evictions: d.evictions,
+	}
+}
+
+// DiskWriter stores the content written to it as a new entry on Commit.
+// Writes never fail, so that it can be combined with the response writer
+// by io.MultiWriter; errors and oversized content only cause Commit to skip
+// storing the entry.
+type DiskWriter struct {
+	d    *Disk
+	meta DiskMeta
+	f    *os.File
+	h    hash.Hash
+	n    int64
+	err  error
+}
+
+// NewWriter starts writing a new entry for key with the given metadata.
+// Key, Hash and Size of meta are filled in by the cache.
+func (d *Disk) NewWriter(key string, meta DiskMeta) *DiskWriter {
+	meta.Key = key
+	w := &DiskWriter{d: d, meta: meta, h: sha256.New()}
+	w.f, w.err = os.CreateTemp(filepath.Join(d.dir, "tmp"), "blob-*")
+	return w
+}
+
+func (w *DiskWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return len(p), nil
+	}
+	w.n += int64(len(p))
+	if w.n > w.d.maxEntrySize {
+		w.err = errEntryTooLarge
+		return len(p), nil
+	}
+	if _, err := w.f.Write(p); err != nil {
+		w.err = err
+		return len(p), nil
+	}
+	w.h.Write(p)
+	return len(p), nil
+}
+
+var errEntryTooLarge = errors.New("entry is too large to cache")
+
+// Commit stores the written content. It returns false if nothing was stored.
+func (w *DiskWriter) Commit() bool {
+	if w.f == nil {
+		return false
+	}
+	tmp := w.f.Name()
+	defer func() { _ = os.Remove(tmp) }()
+
+	if err := w.f.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if w.err != nil {
+		if !errors.Is(w.err, errEntryTooLarge) {
+			log.Printf("warn: failed to write cache entry (key=%s): %v", w.meta.Key, w.err)
+		}
+		return false
+	}
+
+	d := w.d
+	w.meta.Hash = hex.EncodeToString(w.h.Sum(nil))
+	w.meta.Size = w.n
+	if w.meta.ValidatedAt.IsZero() {
+		w.meta.ValidatedAt = time.Now()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Remove the replaced entry first, so that an unchanged blob is not deleted with it.
+	if el, ok := d.entries[w.meta.Key]; ok {
+		d.removeElement(el)
+	}
+
+	blob := d.blobPath(w.meta.Hash)
+	if d.blobRefs[w.meta.Hash] == 0 {
+		if err := os.MkdirAll(filepath.Dir(blob), 0o755); err != nil {
+			log.Printf("warn: failed to create cache directory: %v", err)
+			return false
+		}
+		if err := os.Rename(tmp, blob); err != nil {
+			log.Printf("warn: failed to store cache blob (key=%s): %v", w.meta.Key, err)
+			return false
+		}
+	}
+	if err := d.writeMeta(w.meta); err != nil {
+		log.Printf("warn: %v", err)
+	}
+
+	d.insert(w.meta)
+	d.evict()
+	return true
+}
+
+// Abort discards the written content.
+func (w *DiskWriter) Abort() {
+	if w.f == nil {
+		return
+	}
+	_ = w.f.Close()
+	_ = os.Remove(w.f.Name())
+}
+
+// insert adds m as the most recently used entry. d.mu must be held.
+func (d *Disk) insert(m DiskMeta) {
+	d.entries[m.Key] = d.ll.PushFront(m)
+	if d.blobRefs[m.Hash] == 0 {
+		d.size += m.Size
+	}
+	d.blobRefs[m.Hash]++
+}
+
+// evict removes least recently used entries until the cache fits. d.mu must be held.
+func (d *Disk) evict() {
+	for d.size > d.maxSize {
+		oldest := d.ll.Back()
+		if oldest == nil {
+			break
+		}
+		d.removeElement(oldest)
+		d.evictions++
+	}
+}
+
+// removeElement removes the entry and its blob if no other key refers to it. d.mu must be held.
+func (d *Disk) removeElement(el *list.Element) {
+	m := el.Value.(DiskMeta)
+	d.ll.Remove(el)
+	delete(d.entries, m.Key)
+	_ = os.Remove(d.metaPath(m.Key))
+
+	d.blobRefs[m.Hash]--
+	if d.blobRefs[m.Hash] <= 0 {
+		delete(d.blobRefs, m.Hash)
+		d.size -= m.Size
+		_ = os.Remove(d.blobPath(m.Hash))
+	}
+}
+
+func (d *Disk) writeMeta(m DiskMeta) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache metadata: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Join(d.dir, "tmp"), "meta-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache metadata: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write cache metadata: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache metadata: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), d.metaPath(m.Key)); err != nil {
+		return fmt.Errorf("failed to write cache metadata: %w", err)
+	}
+	return nil
+}
+
+func (d *Disk) blobPath(sum string) string {
+	if len(sum) < 2 {
+		return filepath.Join(d.dir, "blobs", sum)
+	}
+	return filepath.Join(d.dir, "blobs", sum[:2], sum)
+}
+
+func (d *Disk) metaPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, "meta", hex.EncodeToString(sum[:])+".json")
+}
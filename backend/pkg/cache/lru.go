@@ -0,0 +1,94 @@
+// Package cache はアプリ内で共有するキャッシュの汎用実装を提供します。
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is a concurrency-safe least-recently-used cache bounded by the total
+// size of its values (as reported by sizeOf), not by the number of entries.
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	maxSize  int64
+	size     int64
+	sizeOf   func(V) int64
+	ll       *list.List
+	elements map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+	size  int64
+}
+
+// NewLRU creates an LRU holding at most maxSize worth of values.
+func NewLRU[K comparable, V any](maxSize int64, sizeOf func(V) int64) *LRU[K, V] {
+	return &LRU[K, V]{
+		maxSize:  maxSize,
+		sizeOf:   sizeOf,
+		ll:       list.New(),
+		elements: make(map[K]*list.Element),
+	}
+}
+
+// Get returns the value for key and marks it as recently used.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.elements[key]; ok {
+		c.ll.MoveToFront(el)
+		return el.Value.(*lruEntry[K, V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Add stores value for key, evicting least recently used entries as needed.
+// Values larger than the whole cache are not stored.
+func (c *LRU[K, V]) Add(key K, value V) {
+	size := c.sizeOf(value)
+	if size > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.elements[key]; ok {
+		e := el.Value.(*lruEntry[K, V])
+		c.size += size - e.size
+		e.value, e.size = value, size
+		c.ll.MoveToFront(el)
+	} else {
+		c.elements[key] = c.ll.PushFront(&lruEntry[K, V]{key: key, value: value, size: size})
+		c.size += size
+	}
+
+	for c.size > c.maxSize {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.removeElement(oldest)
+	}
+}
+
+// Remove deletes key from the cache.
+func (c *LRU[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.elements[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *LRU[K, V]) removeElement(el *list.Element) {
+	e := el.Value.(*lruEntry[K, V])
+	c.ll.Remove(el)
+	delete(c.elements, e.key)
+	c.size -= e.size
+}
@@ -0,0 +1,49 @@
+package cache
+
+import "time"
+
+// TTL is a concurrency-safe cache whose entries expire a fixed duration after
+// they are added. The number of entries is bounded; when full, the least
+// recently used entries are evicted first.
+type TTL[K comparable, V any] struct {
+	ttl time.Duration
+	lru *LRU[K, ttlEntry[V]]
+}
+
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// NewTTL creates a TTL cache holding at most maxEntries entries for ttl each.
+func NewTTL[K comparable, V any](ttl time.Duration, maxEntries int) *TTL[K, V] {
+	return &TTL[K, V]{
+		ttl: ttl,
+		lru: NewLRU[K](int64(maxEntries), func(ttlEntry[V]) int64 { return 1 }),
+	}
+}
+
+// Get returns the value for key if it has not expired.
+func (c *TTL[K, V]) Get(key K) (V, bool) {
+	e, ok := c.lru.Get(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if time.Now().After(e.expiresAt) {
+		c.lru.Remove(key)
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set stores value for key, replacing any previous value and resetting its expiry.
+func (c *TTL[K, V]) Set(key K, value V) {
+	c.lru.Add(key, ttlEntry[V]{value: value, expiresAt: time.Now().Add(c.ttl)})
+}
+
+// Remove deletes key from the cache.
+func (c *TTL[K, V]) Remove(key K) {
+	c.lru.Remove(key)
+}
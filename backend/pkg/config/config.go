@@ -3,7 +3,10 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 )
@@ -40,6 +43,110 @@ func AppAddr() string {
 	return ":8080"
 }
 
+// ========== traQ ==========
+// TraqBaseURL は接続先traQのベースURL。ステージングやローカルのtraQに向ける場合に変更する
+func TraqBaseURL() string {
+	return getEnv("TRAQ_BASE_URL", "https://q.trap.jp")
+}
+
+// TraqRetryMax はtraQへの冪等なリクエストを 429/5xx・通信エラーで再試行する回数（既定 3回）
+func TraqRetryMax() int {
+	n, err := strconv.Atoi(getEnv("TRAQ_RETRY_MAX", ""))
+	if err != nil || n < 0 {
+		return 3
+	}
+	return n
+}
+
+// TraqRetryBaseDelay は最初の再試行までの待ち時間の上限。再試行のたびに倍になる（既定 100ms）
+func TraqRetryBaseDelay() time.Duration {
+	d, err := time.ParseDuration(getEnv("TRAQ_RETRY_BASE_DELAY", ""))
+	if err != nil || d <= 0 {
+		return 100 * time.Millisecond
+	}
+	return d
+}
+
+// TraqCircuitFailureThreshold はtraQへのリクエストを止めるまでの連続した失敗の回数（既定 5回）
+func TraqCircuitFailureThreshold() int {
+	n, err := strconv.Atoi(getEnv("TRAQ_CIRCUIT_FAILURE_THRESHOLD", ""))
+	if err != nil || n <= 0 {
+		return 5
+	}
+	return n
+}
+
+// TraqCircuitOpenDuration はtraQへのリクエストを止めておく期間（既定 30秒）
+func TraqCircuitOpenDuration() time.Duration {
+	d, err := time.ParseDuration(getEnv("TRAQ_CIRCUIT_OPEN_DURATION", ""))
+	if err != nil || d <= 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
+// TraqMaxConcurrentRequests はtraQへ同時に送るリクエストの数の上限。超えた分は順番待ちになる（既定 32）
+func TraqMaxConcurrentRequests() int {
+	n, err := strconv.Atoi(getEnv("TRAQ_MAX_CONCURRENT_REQUESTS", ""))
+	if err != nil || n <= 0 {
+		return 32
+	}
+	return n
+}
+
+// ========== traQ file cache ==========
+// FileCacheDir はtraQから取得したファイルをキャッシュするディレクトリ。空文字列ならキャッシュしない
+func FileCacheDir() string {
+	return getEnv("FILE_CACHE_DIR", filepath.Join(os.TempDir(), "1m25_10", "traq-files"))
+}
+
+// FileCacheMaxBytes はファイルキャッシュの容量の上限（既定 1GiB）
+func FileCacheMaxBytes() int64 {
+	n, err := strconv.ParseInt(getEnv("FILE_CACHE_MAX_BYTES", ""), 10, 64)
+	if err != nil || n <= 0 {
+		return 1 << 30
+	}
+	return n
+}
+
+// ========== local image index ==========
+// TraqServiceToken はバックグラウンドでtraQをクロールするためのアクセストークン（BOTのトークンなど）。空文字列ならクロールしない
+func TraqServiceToken() string {
+	return getEnv("TRAQ_SERVICE_TOKEN", "")
+}
+
+// IndexerInterval はクロールの間隔（既定 5分）
+func IndexerInterval() time.Duration {
+	d, err := time.ParseDuration(getEnv("INDEXER_INTERVAL", ""))
+	if err != nil || d <= 0 {
+		return 5 * time.Minute
+	}
+	return d
+}
+
+// IndexerRecrawlWindow はスタンプの更新を反映するために、毎回クロールし直す直近の期間（既定 24時間）
+func IndexerRecrawlWindow() time.Duration {
+	d, err := time.ParseDuration(getEnv("INDEXER_RECRAWL_WINDOW", ""))
+	if err != nil || d < 0 {
+		return 24 * time.Hour
+	}
+	return d
+}
+
+// IndexerPollInterval は直近の投稿を取り込んでライブフィードに配信する間隔（既定 15秒、0 ならポーリングしない）
+func IndexerPollInterval() time.Duration {
+	d, err := time.ParseDuration(getEnv("INDEXER_POLL_INTERVAL", ""))
+	if err != nil || d < 0 {
+		return 15 * time.Second
+	}
+	return d
+}
+
+// TraqBotVerificationToken はtraQ BOT (HTTPモード) のイベントを検証するトークン。空文字列ならイベントを受け付けない
+func TraqBotVerificationToken() string {
+	return getEnv("TRAQ_BOT_VERIFICATION_TOKEN", "")
+}
+
 // ========== traQ OAuth ==========
 func TraqOAuthClientID() string {
 	return getEnv("TRAQ_OAUTH_CLIENT_ID", "")
@@ -54,6 +161,22 @@ func TraqOAuthRedirectURI() string {
 	return getEnv("TRAQ_OAUTH_REDIRECT_URI", "")
 }
 
+// ========== sessions ==========
+// SessionEncryptionKey はセッションに保存する traQ のアクセストークンを暗号化する鍵（32バイトを base64 または hex で）。
+// 空文字列なら起動ごとに鍵を生成する（再起動するとログインし直しになる）
+func SessionEncryptionKey() string {
+	return getEnv("SESSION_ENCRYPTION_KEY", "")
+}
+
+// SessionMaxAge はセッションの有効期間の上限（既定 30日）。traQ のトークンの有効期限の方が短ければそちらに合わせる
+func SessionMaxAge() time.Duration {
+	d, err := time.ParseDuration(getEnv("SESSION_MAX_AGE", ""))
+	if err != nil || d <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return d
+}
+
 // 開発/本番のベースURL
 func ServerBaseURL() string {
 	// 例: http://localhost:8080
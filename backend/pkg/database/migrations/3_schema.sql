@@ -0,0 +1,3 @@
+-- +goose Up
+-- 知覚ハッシュ(dHash)。重複画像の検出に利用する
+ALTER TABLE images ADD COLUMN phash BIGINT UNSIGNED NULL;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- 元画像のEXIFから抽出した撮影情報と、画像が投稿されたメッセージの投稿日時
+ALTER TABLE images
+    ADD COLUMN taken_at DATETIME NULL,
+    ADD COLUMN camera_model VARCHAR(255) NULL,
+    ADD COLUMN orientation TINYINT NULL,
+    ADD COLUMN posted_at DATETIME NULL,
+    ADD COLUMN metadata_extracted_at DATETIME NULL;
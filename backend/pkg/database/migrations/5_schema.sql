@@ -0,0 +1,5 @@
+-- +goose Up
+-- サムネイルから計算したプレースホルダー表示用の情報
+ALTER TABLE images
+    ADD COLUMN blurhash VARCHAR(64) NULL,
+    ADD COLUMN dominant_color CHAR(7) NULL;
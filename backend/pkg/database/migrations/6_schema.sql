@@ -0,0 +1,14 @@
+-- +goose Up
+-- 画像の主要色(CIE L*a*b*)。色による画像検索に利用する
+CREATE TABLE IF NOT EXISTS image_colors (
+    image_id VARCHAR(36) NOT NULL,
+    `rank` TINYINT NOT NULL,
+    l DOUBLE NOT NULL,
+    a DOUBLE NOT NULL,
+    b DOUBLE NOT NULL,
+    weight DOUBLE NOT NULL,
+    PRIMARY KEY (image_id, `rank`),
+    FOREIGN KEY (image_id) REFERENCES images(id)
+);
+-- サムネイル解析のバージョン。解析項目を増やしたときに再解析の要否を判定する
+ALTER TABLE images ADD COLUMN analysis_version TINYINT NOT NULL DEFAULT 0;
@@ -0,0 +1,39 @@
+-- +goose Up
+-- traQの画像付きメッセージの索引。バックグラウンドのクローラーが更新し、source=local の検索に利用する
+CREATE TABLE IF NOT EXISTS indexed_messages (
+    id VARCHAR(36) NOT NULL,
+    channel_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    stamp_count INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (id),
+    INDEX idx_indexed_messages_channel (channel_id, created_at),
+    INDEX idx_indexed_messages_user (user_id, created_at)
+);
+-- メッセージに含まれる画像（position は本文中での順番）
+CREATE TABLE IF NOT EXISTS indexed_message_images (
+    message_id VARCHAR(36) NOT NULL,
+    image_id VARCHAR(36) NOT NULL,
+    position INT NOT NULL,
+    PRIMARY KEY (message_id, image_id),
+    INDEX idx_indexed_message_images_image (image_id),
+    FOREIGN KEY (message_id) REFERENCES indexed_messages(id),
+    FOREIGN KEY (image_id) REFERENCES images(id)
+);
+-- メッセージに付いたスタンプ（ユーザーごと）
+CREATE TABLE IF NOT EXISTS indexed_message_stamps (
+    message_id VARCHAR(36) NOT NULL,
+    stamp_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    count INT NOT NULL,
+    PRIMARY KEY (message_id, stamp_id, user_id),
+    FOREIGN KEY (message_id) REFERENCES indexed_messages(id)
+);
+-- クローラーの進捗。high_water_mark はクロール済みのメッセージの最新の投稿日時
+CREATE TABLE IF NOT EXISTS indexer_state (
+    name VARCHAR(64) NOT NULL,
+    high_water_mark DATETIME(6) NULL,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (name)
+);
@@ -0,0 +1,17 @@
+-- +goose Up
+-- ログインセッション。Cookie にはセッションの秘密の値だけを置き、traQ のアクセストークンは暗号化して保存する
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(36) NOT NULL,
+    -- Cookie の値の SHA-256（16進）。Cookie の値そのものは保存しない
+    secret_hash CHAR(64) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    -- AES-GCM で暗号化した traQ のアクセストークン（先頭に nonce）
+    encrypted_token VARBINARY(1024) NOT NULL,
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    created_at DATETIME(6) NOT NULL,
+    expires_at DATETIME(6) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_sessions_secret_hash (secret_hash),
+    INDEX idx_sessions_user (user_id, expires_at),
+    INDEX idx_sessions_expires_at (expires_at)
+);
@@ -0,0 +1,136 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// colorSampleSize は色の集計前に縮小する最大の辺の長さ
+const colorSampleSize = 64
+
+// PaletteColor is one of the main colors of an image.
+type PaletteColor struct {
+	Color  color.RGBA
+	Weight float64 // fraction of the (opaque) pixels in this color, 0-1
+}
+
+// Palette returns up to n main colors of img, most frequent first. Colors are
+// bucketed (4 bits per channel) so that slight variations count as the same
+// color, and the average of each bucket is returned. Mostly transparent pixels are ignored.
+func Palette(img image.Image, n int) []PaletteColor {
+	img = Resize(img, colorSampleSize, colorSampleSize, FitContain)
+	b := img.Bounds()
+
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := make(map[uint16]*bucket)
+	total := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			r, g, bl = r>>8, g>>8, bl>>8
+			key := uint16(r>>4)<<8 | uint16(g>>4)<<4 | uint16(bl>>4)
+			bk, ok := buckets[key]
+			if !ok {
+				bk = &bucket{}
+				buckets[key] = bk
+			}
+			bk.count++
+			bk.r += int(r)
+			bk.g += int(g)
+			bk.b += int(bl)
+			total++
+		}
+	}
+
+	sorted := make([]*bucket, 0, len(buckets))
+	for _, bk := range buckets {
+		sorted = append(sorted, bk)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].count > sorted[j].count })
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+
+	palette := make([]PaletteColor, 0, len(sorted))
+	for _, bk := range sorted {
+		palette = append(palette, PaletteColor{
+			Color: color.RGBA{
+				R: uint8(bk.r / bk.count),
+				G: uint8(bk.g / bk.count),
+				B: uint8(bk.b / bk.count),
+				A: 0xff,
+			},
+			Weight: float64(bk.count) / float64(total),
+		})
+	}
+	return palette
+}
+
+// DominantColor returns the most frequent color of img (black for fully transparent images).
+func DominantColor(img image.Image) color.RGBA {
+	p := Palette(img, 1)
+	if len(p) == 0 {
+		return color.RGBA{A: 0xff}
+	}
+	return p[0].Color
+}
+
+// HexColor formats c as "#rrggbb".
+func HexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// ParseHexColor parses "#rrggbb" (the leading '#' is optional).
+func ParseHexColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid hex color: %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid hex color: %q", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// Lab is a color in CIE L*a*b* (D65), where Euclidean distance roughly
+// matches perceived color difference.
+type Lab struct {
+	L, A, B float64
+}
+
+// ToLab converts an sRGB color to CIE L*a*b*.
+func ToLab(c color.RGBA) Lab {
+	r := srgbToLinear(uint32(c.R))
+	g := srgbToLinear(uint32(c.G))
+	b := srgbToLinear(uint32(c.B))
+
+	// linear sRGB -> XYZ (D65), 白色点で正規化
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / 0.95047
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389.0 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27.0*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return Lab{
+		L: 116*fy - 16,
+		A: 500 * (fx - fy),
+		B: 200 * (fy - fz),
+	}
+}
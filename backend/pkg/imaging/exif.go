@@ -0,0 +1,227 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNoExif is returned when the image has no readable EXIF segment.
+var ErrNoExif = errors.New("no exif data")
+
+// Exif holds the subset of EXIF tags the app cares about.
+type Exif struct {
+	TakenAt     *time.Time
+	CameraModel string
+	Orientation int // 1-8, 0 if unknown
+}
+
+const (
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagExifIFDPointer     = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+
+	typeShort = 3
+	typeLong  = 4
+
+	// APP1セグメントの最大長(2バイト長フィールドの上限)
+	maxSegmentSize = 0xffff
+)
+
+// ParseJPEGExif reads JPEG markers from r until it finds the EXIF (APP1)
+// segment and decodes it. Only the header of the file is consumed, so r can
+// be a streaming HTTP body. DateTimeOriginal has no zone in EXIF; when
+// OffsetTimeOriginal is absent it is interpreted in loc.
+func ParseJPEGExif(r io.Reader, loc *time.Location) (*Exif, error) {
+	br := bufio.NewReader(r)
+
+	var soi [2]byte
+	if _, err := io.ReadFull(br, soi[:]); err != nil {
+		return nil, err
+	}
+	if soi[0] != 0xff || soi[1] != 0xd8 {
+		return nil, errors.New("not a jpeg")
+	}
+
+	for {
+		marker, err := nextMarker(br)
+		if err != nil {
+			return nil, err
+		}
+		// SOS 以降は画像データなのでEXIFは無い
+		if marker == 0xda || marker == 0xd9 {
+			return nil, ErrNoExif
+		}
+		// 長さを持たないマーカー
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+			continue
+		}
+
+		var lenBuf [2]byte
+		if _, err := io.ReadFull(br, lenBuf[:]); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(lenBuf[:])) - 2
+		if length < 0 {
+			return nil, errors.New("invalid segment length")
+		}
+
+		if marker != 0xe1 {
+			if _, err := br.Discard(length); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		seg := make([]byte, length)
+		if _, err := io.ReadFull(br, seg); err != nil {
+			return nil, err
+		}
+		if !bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			// XMPなど別用途のAPP1
+			continue
+		}
+		return parseTIFF(seg[6:], loc)
+	}
+}
+
+func nextMarker(br *bufio.Reader) (byte, error) {
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xff {
+		return 0, fmt.Errorf("invalid jpeg marker prefix: 0x%02x", b)
+	}
+	// フィルバイト(0xff)を読み飛ばす
+	for b == 0xff {
+		if b, err = br.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+	return b, nil
+}
+
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte // raw 4-byte value/offset field
+}
+
+func parseTIFF(data []byte, loc *time.Location) (*Exif, error) {
+	if len(data) < 8 {
+		return nil, ErrNoExif
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("invalid tiff byte order")
+	}
+
+	ifd0, err := readIFD(data, order, order.Uint32(data[4:8]))
+	if err != nil {
+		return nil, err
+	}
+
+	ex := &Exif{}
+	var dateTimeOriginal, offsetTimeOriginal string
+	for _, e := range ifd0 {
+		switch e.tag {
+		case tagModel:
+			ex.CameraModel = readASCII(data, order, e)
+		case tagOrientation:
+			if e.typ == typeShort {
+				ex.Orientation = int(order.Uint16(e.value))
+			}
+		case tagExifIFDPointer:
+			if e.typ != typeLong {
+				continue
+			}
+			sub, err := readIFD(data, order, order.Uint32(e.value))
+			if err != nil {
+				return nil, err
+			}
+			for _, se := range sub {
+				switch se.tag {
+				case tagDateTimeOriginal:
+					dateTimeOriginal = readASCII(data, order, se)
+				case tagOffsetTimeOriginal:
+					offsetTimeOriginal = readASCII(data, order, se)
+				}
+			}
+		}
+	}
+
+	if dateTimeOriginal != "" {
+		if t, ok := parseExifTime(dateTimeOriginal, offsetTimeOriginal, loc); ok {
+			ex.TakenAt = &t
+		}
+	}
+	return ex, nil
+}
+
+func readIFD(data []byte, order binary.ByteOrder, offset uint32) ([]ifdEntry, error) {
+	if int(offset)+2 > len(data) {
+		return nil, errors.New("ifd offset out of range")
+	}
+	n := int(order.Uint16(data[offset:]))
+	p := int(offset) + 2
+	if p+n*12 > len(data) {
+		return nil, errors.New("ifd entries out of range")
+	}
+
+	entries := make([]ifdEntry, 0, n)
+	for i := 0; i < n; i++ {
+		b := data[p+i*12 : p+(i+1)*12]
+		entries = append(entries, ifdEntry{
+			tag:   order.Uint16(b[0:2]),
+			typ:   order.Uint16(b[2:4]),
+			count: order.Uint32(b[4:8]),
+			value: b[8:12],
+		})
+	}
+	return entries, nil
+}
+
+func readASCII(data []byte, order binary.ByteOrder, e ifdEntry) string {
+	var raw []byte
+	if e.count <= 4 {
+		raw = e.value[:e.count]
+	} else {
+		off := order.Uint32(e.value)
+		end := uint64(off) + uint64(e.count)
+		if end > uint64(len(data)) {
+			return ""
+		}
+		raw = data[off:end]
+	}
+	return strings.TrimSpace(strings.TrimRight(string(raw), "\x00"))
+}
+
+func parseExifTime(dt, offset string, loc *time.Location) (time.Time, bool) {
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", dt+offset); err == nil {
+			return t, true
+		}
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", dt, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,71 @@
+// Package imaging は画像の解析（知覚ハッシュなど）を行う汎用パッケージです。
+package imaging
+
+import (
+	"image"
+	"math/bits"
+)
+
+// DHash computes a 64-bit difference hash of img.
+// The image is reduced to a 9x8 grayscale grid and each bit records whether
+// a cell is brighter than its right neighbour, so visually identical images
+// (re-encoded, resized, slightly recompressed) produce the same or nearby hashes.
+func DHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	gray := downsampleGray(img, w, h)
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if gray[y*w+x] > gray[y*w+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance returns the Hamming distance between two hashes.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// downsampleGray reduces img to a w x h grid of luminance values by averaging
+// every source pixel that falls into each cell (box filter).
+func downsampleGray(img image.Image, w, h int) []float64 {
+	bounds := img.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	out := make([]float64, w*h)
+	if sw == 0 || sh == 0 {
+		return out
+	}
+
+	for ty := 0; ty < h; ty++ {
+		y0 := bounds.Min.Y + ty*sh/h
+		y1 := bounds.Min.Y + (ty+1)*sh/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for tx := 0; tx < w; tx++ {
+			x0 := bounds.Min.X + tx*sw/w
+			x1 := bounds.Min.X + (tx+1)*sw/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var sum float64
+			var n int
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					r, g, b, _ := img.At(x, y).RGBA()
+					// ITU-R BT.601 luma
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					n++
+				}
+			}
+			out[ty*w+tx] = sum / float64(n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package imaging
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// Fit modes for Resize.
+const (
+	// FitContain scales the image to fit inside the box, keeping the aspect ratio.
+	FitContain = "contain"
+	// FitCover scales the image to fill the box and crops the overflow around the center.
+	FitCover = "cover"
+)
+
+// Resize scales img into a w x h box according to fit. A zero w or h leaves
+// that dimension unconstrained. Images are never enlarged.
+func Resize(img image.Image, w, h int, fit string) image.Image {
+	src := img.Bounds()
+	sw, sh := src.Dx(), src.Dy()
+	if sw == 0 || sh == 0 {
+		return img
+	}
+	if w <= 0 && h <= 0 {
+		return img
+	}
+
+	// 片方しか指定されていない場合は contain として扱う
+	if w <= 0 || h <= 0 {
+		fit = FitContain
+	}
+
+	scale := 1.0
+	switch fit {
+	case FitCover:
+		scale = max(float64(w)/float64(sw), float64(h)/float64(sh))
+	default:
+		sx, sy := float64(w)/float64(sw), float64(h)/float64(sh)
+		switch {
+		case w <= 0:
+			scale = sy
+		case h <= 0:
+			scale = sx
+		default:
+			scale = min(sx, sy)
+		}
+	}
+	if scale > 1 {
+		scale = 1
+	}
+
+	dw := max(1, int(float64(sw)*scale+0.5))
+	dh := max(1, int(float64(sh)*scale+0.5))
+
+	// cover の場合は拡大後にはみ出す部分を中央基準で切り落とす
+	crop := src
+	if fit == FitCover {
+		cw, ch := min(dw, w), min(dh, h)
+		// 切り出す範囲を元画像の座標系に戻す
+		srcW := int(float64(cw)/scale + 0.5)
+		srcH := int(float64(ch)/scale + 0.5)
+		x0 := src.Min.X + (sw-srcW)/2
+		y0 := src.Min.Y + (sh-srcH)/2
+		crop = image.Rect(x0, y0, x0+srcW, y0+srcH).Intersect(src)
+		dw, dh = cw, ch
+	}
+
+	if dw == sw && dh == sh && crop == src {
+		return img
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,76 @@
+package traq
+
+import "time"
+
+// Headers of the requests traQ sends to bots in HTTP mode.
+const (
+	BotEventHeader     = "X-TRAQ-BOT-EVENT"
+	BotTokenHeader     = "X-TRAQ-BOT-TOKEN"
+	BotRequestIDHeader = "X-TRAQ-BOT-REQUEST-ID"
+)
+
+// Bot event types the app handles.
+const (
+	BotEventPing                    = "PING"
+	BotEventMessageCreated          = "MESSAGE_CREATED"
+	BotEventMessageDeleted          = "MESSAGE_DELETED"
+	BotEventBotMessageStampsUpdated = "BOT_MESSAGE_STAMPS_UPDATED"
+)
+
+// BotEventUser is a user in bot event payloads.
+type BotEventUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	IconID      string `json:"iconId"`
+	Bot         bool   `json:"bot"`
+}
+
+// BotEventMessage is a message in bot event payloads.
+type BotEventMessage struct {
+	ID        string       `json:"id"`
+	User      BotEventUser `json:"user"`
+	ChannelID string       `json:"channelId"`
+	Text      string       `json:"text"`
+	PlainText string       `json:"plainText"`
+	CreatedAt time.Time    `json:"createdAt"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+}
+
+// PingEvent is the payload of PING.
+type PingEvent struct {
+	EventTime time.Time `json:"eventTime"`
+}
+
+// MessageCreatedEvent is the payload of MESSAGE_CREATED.
+type MessageCreatedEvent struct {
+	EventTime time.Time       `json:"eventTime"`
+	Message   BotEventMessage `json:"message"`
+}
+
+// MessageDeletedEvent is the payload of MESSAGE_DELETED.
+type MessageDeletedEvent struct {
+	EventTime time.Time `json:"eventTime"`
+	Message   struct {
+		ID        string `json:"id"`
+		ChannelID string `json:"channelId"`
+	} `json:"message"`
+}
+
+// BotMessageStampsUpdatedEvent is the payload of BOT_MESSAGE_STAMPS_UPDATED.
+// Stamps holds all stamps on the message after the update.
+type BotMessageStampsUpdatedEvent struct {
+	EventTime time.Time         `json:"eventTime"`
+	MessageID string            `json:"messageId"`
+	Stamps    []BotMessageStamp `json:"stamps"`
+}
+
+// BotMessageStamp is a stamp a user put on a message, in bot event payloads.
+type BotMessageStamp struct {
+	StampID   string    `json:"stampId"`
+	UserID    string    `json:"userId"`
+	StampName string    `json:"stampName"`
+	Count     int       `json:"count"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
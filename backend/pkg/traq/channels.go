@@ -0,0 +1,44 @@
+package traq
+
+import "context"
+
+// Channel is a public traQ channel.
+type Channel struct {
+	ID       string   `json:"id"`
+	ParentID *string  `json:"parentId"`
+	Archived bool     `json:"archived"`
+	Force    bool     `json:"force"`
+	Topic    string   `json:"topic"`
+	Name     string   `json:"name"`
+	Children []string `json:"children"`
+}
+
+// DMChannel is a direct message channel.
+type DMChannel struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+}
+
+// ChannelList is the response of the channel list API.
+type ChannelList struct {
+	Public []Channel   `json:"public"`
+	DM     []DMChannel `json:"dm,omitempty"`
+}
+
+// GetChannels returns all public channels.
+func (c *Client) GetChannels(ctx context.Context, token string) (*ChannelList, error) {
+	var list ChannelList
+	if err := c.getJSON(ctx, token, "/channels", nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetChannel returns a channel.
+func (c *Client) GetChannel(ctx context.Context, token, channelID string) (*Channel, error) {
+	var ch Channel
+	if err := c.getJSON(ctx, token, "/channels/"+channelID, nil, &ch); err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
@@ -0,0 +1,201 @@
+// Package traq は traQ API (v3) のクライアントです。
+// ベースURLを差し替えることで、本番以外の traQ やテスト用のサーバーにも接続できます。
+package traq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const apiPrefix = "/api/v3"
+
+// Client is a traQ API client. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	fileURLRe  *regexp.Regexp
+
+	// 同時に送られた同じ GET リクエスト（JSON の API とサムネイル）をまとめる
+	group singleflight.Group
+	// 同時に送るリクエストの数の上限（nil なら無制限）
+	sem chan struct{}
+}
+
+// New creates a Client for the traQ instance at baseURL (e.g. "https://q.trap.jp").
+// If httpClient is nil, http.DefaultClient will be used.
+func New(baseURL string, httpClient *http.Client, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid traQ base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid traQ base URL: %q", baseURL)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: httpClient,
+		// メッセージ本文中のファイルURL (https://q.trap.jp/files/<uuid>)
+		fileURLRe: regexp.MustCompile(`https?://` + regexp.QuoteMeta(u.Host) +
+			`/files/([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})`),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// BaseURL returns the base URL of the traQ instance without a trailing slash.
+func (c *Client) BaseURL() string {
+	return c.baseURL.String()
+}
+
+// FileURL returns the URL under which a file is embedded in message contents.
+func (c *Client) FileURL(fileID string) string {
+	return c.BaseURL() + "/files/" + fileID
+}
+
+// MessageURL returns the URL under which a message is cited in message contents.
+func (c *Client) MessageURL(messageID string) string {
+	return c.BaseURL() + "/messages/" + messageID
+}
+
+// ExtractFileIDs returns the IDs of all files embedded in a message content, in order of appearance.
+func (c *Client) ExtractFileIDs(content string) []string {
+	matches := c.fileURLRe.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	res := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if len(m) >= 2 {
+			res = append(res, m[1])
+		}
+	}
+	return res
+}
+
+func (c *Client) endpoint(path string, query url.Values) string {
+	u := *c.baseURL
+	u.Path += apiPrefix + path
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// do sends a request to the traQ API. token may be empty for unauthenticated endpoints.
+// The response is returned as-is regardless of its status code; the caller must close the body.
+// The response body is streamed and the request is aborted when ctx is canceled.
+func (c *Client) do(ctx context.Context, token, method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := c.newRequest(ctx, token, method, path, query, body, header)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(req)
+}
+
+// doStream sends a request like do, but the response does not hold a slot for concurrent
+// requests while its body is read. It is used for large bodies that are streamed to clients.
+func (c *Client) doStream(ctx context.Context, token, path string, header http.Header) (*http.Response, error) {
+	req, err := c.newRequest(ctx, token, http.MethodGet, path, nil, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	return c.sendStream(req)
+}
+
+// doShared sends a GET request like do, but identical requests in flight at the same time
+// are sent to traQ only once. The response body is read into memory to be shared,
+// so it must only be used for small responses (JSON and thumbnails).
+func (c *Client) doShared(ctx context.Context, token, path string, query url.Values, header http.Header) (*http.Response, error) {
+	req, err := c.newRequest(ctx, token, http.MethodGet, path, query, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	return c.doCoalesced(req)
+}
+
+func (c *Client) newRequest(ctx context.Context, token, method, path string, query url.Values, body io.Reader, header http.Header) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint(path, query), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// getJSON sends a GET request and decodes a 2xx JSON response into out.
+// Non-2xx responses are returned as *APIError. Identical requests in flight at the same time are shared.
+func (c *Client) getJSON(ctx context.Context, token, path string, query url.Values, out interface{}) error {
+	resp, err := c.doShared(ctx, token, path, query, nil)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode traQ response (%s): %w", path, err)
+	}
+	return nil
+}
+
+// sendJSON sends a request with in encoded as the JSON body and decodes a 2xx JSON response into out.
+// in and out may be nil. Non-2xx responses are returned as *APIError.
+func (c *Client) sendJSON(ctx context.Context, token, method, path string, in, out interface{}) error {
+	var body io.Reader
+	var header http.Header
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode traQ request (%s): %w", path, err)
+		}
+		body = bytes.NewReader(b)
+		header = http.Header{"Content-Type": []string{"application/json"}}
+	}
+	resp, err := c.do(ctx, token, method, path, nil, body, header)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode traQ response (%s): %w", path, err)
+	}
+	return nil
+}
+
+func closeBody(resp *http.Response) {
+	if cerr := resp.Body.Close(); cerr != nil {
+		log.Printf("warn: failed to close response body: %v", cerr)
+	}
+}
@@ -0,0 +1,160 @@
+package traq
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// 同じリクエストの応答を共有するためにメモリに読み込む本文の上限。
+// 共有するのは JSON とサムネイルだけなので、これより大きい応答は想定しない（来た場合は呼び出し元ごとに取得し直す）
+const maxCoalescedBodyBytes = 4 << 20
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithMaxConcurrentRequests limits the number of requests sent to traQ at the same time.
+// Requests over the limit wait in a queue until a running request finishes or their context is canceled.
+// A response counts as running until its body is closed, except for file downloads (GetFile),
+// which are streamed and count only until the response headers arrive.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.sem = make(chan struct{}, n)
+		} else {
+			c.sem = nil
+		}
+	}
+}
+
+// sharedResponse は同時に送られた同じ GET リクエストで共有する応答
+type sharedResponse struct {
+	resp *http.Response // 本文以外
+	body []byte
+	// 本文が大きすぎて共有できなかった
+	tooLarge bool
+}
+
+// doCoalesced は GET リクエストを送る。同時に送られた同じリクエスト（URL と Authorization を含む全てのヘッダー、
+// つまり呼び出し元の権限も同じもの）は traQ へ1回だけ送り、呼び出し元ごとに応答の複製を返す
+func (c *Client) doCoalesced(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	ch := c.group.DoChan(coalesceKey(req), func() (interface{}, error) {
+		// 呼び出し元のキャンセルが他の待機中のリクエストに波及しないようにする
+		return c.fetchShared(req.WithContext(context.WithoutCancel(ctx)))
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		shared := r.Val.(*sharedResponse)
+		if shared.tooLarge {
+			return c.send(req)
+		}
+		resp := *shared.resp
+		resp.Header = shared.resp.Header.Clone()
+		resp.Body = io.NopCloser(bytes.NewReader(shared.body))
+		resp.ContentLength = int64(len(shared.body))
+		resp.Request = req
+		return &resp, nil
+	}
+}
+
+func (c *Client) fetchShared(req *http.Request) (*sharedResponse, error) {
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	if resp.ContentLength > maxCoalescedBodyBytes {
+		return &sharedResponse{tooLarge: true}, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCoalescedBodyBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read traQ response: %w", err)
+	}
+	if len(body) > maxCoalescedBodyBytes {
+		return &sharedResponse{tooLarge: true}, nil
+	}
+
+	head := *resp
+	head.Body = nil
+	return &sharedResponse{resp: &head, body: body}, nil
+}
+
+// send は同時リクエスト数の枠が空くのを待ってリクエストを送る。枠は応答の本文を閉じるまで使い続ける
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if err := c.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: c.release}
+	return resp, nil
+}
+
+// sendStream は send と同じだが、枠は応答のヘッダーを受け取った時点で返す。
+// ファイルの本文はブラウザへ中継しながら読むため、遅い（止まった）ダウンロードが枠を使い続けて
+// 他のAPIの呼び出しを待たせないようにする
+func (c *Client) sendStream(req *http.Request) (*http.Response, error) {
+	if err := c.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.httpClient.Do(req)
+}
+
+func (c *Client) acquire(ctx context.Context) error {
+	if c.sem == nil {
+		return nil
+	}
+	select {
+	case c.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) release() {
+	if c.sem != nil {
+		<-c.sem
+	}
+}
+
+// coalesceKey はメソッド・URL・全てのヘッダー（Authorization を含む）からリクエストを識別するキーを作る
+func coalesceKey(req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte(' ')
+	b.WriteString(req.URL.String())
+	b.WriteByte('\n')
+	// Header.Write はキーの順に書き出す
+	_ = req.Header.Write(&b)
+	return b.String()
+}
+
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
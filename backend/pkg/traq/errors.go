@@ -0,0 +1,36 @@
+package traq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// APIError is returned when traQ responds with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("traQ API error: status=%d body=%s", e.StatusCode, string(e.Body))
+}
+
+// StatusCode returns the traQ status code carried by err, or 0 if err is not an *APIError.
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// checkResponse returns an *APIError for non-2xx responses, consuming the body.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return &APIError{StatusCode: resp.StatusCode, Body: b}
+}
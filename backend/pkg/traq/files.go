@@ -0,0 +1,57 @@
+package traq
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// FileInfo is the metadata of a file uploaded to traQ.
+type FileInfo struct {
+	ID              string      `json:"id"`
+	Name            string      `json:"name"`
+	Mime            string      `json:"mime"`
+	Size            int64       `json:"size"`
+	MD5             string      `json:"md5"`
+	IsAnimatedImage bool        `json:"isAnimatedImage"`
+	CreatedAt       time.Time   `json:"createdAt"`
+	Thumbnails      []Thumbnail `json:"thumbnails"`
+	ChannelID       *string     `json:"channelId"`
+	UploaderID      *string     `json:"uploaderId"`
+}
+
+// Thumbnail is a thumbnail of a traQ file.
+type Thumbnail struct {
+	Type   string `json:"type"`
+	Mime   string `json:"mime"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// GetFile requests the content of a file. header is added to the request
+// (e.g. conditional or range headers). The response is returned as-is,
+// including non-2xx responses, so that it can be streamed or relayed; the
+// caller must close the body. Files can be large, so concurrent requests for
+// the same file are not shared; the request is aborted when ctx is canceled.
+// The body is not counted against WithMaxConcurrentRequests, so slow downloads
+// do not block other requests.
+func (c *Client) GetFile(ctx context.Context, token, fileID string, header http.Header) (*http.Response, error) {
+	return c.doStream(ctx, token, "/files/"+fileID, header)
+}
+
+// GetFileThumbnail requests the thumbnail of a file. Like GetFile, the
+// response is returned as-is and the caller must close the body.
+// Thumbnails are small, so identical requests in flight at the same time are shared.
+func (c *Client) GetFileThumbnail(ctx context.Context, token, fileID string, header http.Header) (*http.Response, error) {
+	return c.doShared(ctx, token, "/files/"+fileID+"/thumbnail", nil, header)
+}
+
+// GetFileMeta returns the metadata of a file. It also serves as a cheap
+// check that the owner of token can access the file.
+func (c *Client) GetFileMeta(ctx context.Context, token, fileID string) (*FileInfo, error) {
+	var info FileInfo
+	if err := c.getJSON(ctx, token, "/files/"+fileID+"/meta", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
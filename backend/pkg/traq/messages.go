@@ -0,0 +1,140 @@
+package traq
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Message is a traQ message.
+type Message struct {
+	ID        string         `json:"id"`
+	UserID    string         `json:"userId"`
+	ChannelID string         `json:"channelId"`
+	Content   string         `json:"content"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	Pinned    bool           `json:"pinned"`
+	Stamps    []MessageStamp `json:"stamps"`
+	ThreadID  *string        `json:"threadId"`
+}
+
+// MessageStamp is a stamp a user put on a message.
+type MessageStamp struct {
+	UserID    string    `json:"userId"`
+	StampID   string    `json:"stampId"`
+	Count     int       `json:"count"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// MessageSearchResult is the response of the message search API.
+type MessageSearchResult struct {
+	TotalHits int       `json:"totalHits"`
+	Hits      []Message `json:"hits"`
+}
+
+// MessageSearchParams は traQ /api/v3/messages のクエリです。
+type MessageSearchParams struct {
+	Word           string
+	After          string // RFC3339想定 (serverはバリデーションしない)
+	Before         string // RFC3339想定 (serverはバリデーションしない)
+	In             string // channel uuid
+	To             []string
+	From           []string
+	Citation       string // message uuid
+	Bot            *bool
+	HasURL         *bool
+	HasAttachments *bool
+	HasImage       *bool
+	HasVideo       *bool
+	HasAudio       *bool
+	Limit          *int
+	Offset         *int
+	Sort           string // createdAt | -createdAt | updatedAt | -updatedAt
+}
+
+func (p *MessageSearchParams) query() url.Values {
+	q := url.Values{}
+	if p.Word != "" {
+		q.Set("word", p.Word)
+	}
+	if p.After != "" {
+		q.Set("after", p.After)
+	}
+	if p.Before != "" {
+		q.Set("before", p.Before)
+	}
+	if p.In != "" {
+		q.Set("in", p.In)
+	}
+	for _, v := range p.To {
+		if v != "" {
+			q.Add("to", v)
+		}
+	}
+	for _, v := range p.From {
+		if v != "" {
+			q.Add("from", v)
+		}
+	}
+	if p.Citation != "" {
+		q.Set("citation", p.Citation)
+	}
+	setBool := func(key string, v *bool) {
+		if v != nil {
+			q.Set(key, strconv.FormatBool(*v))
+		}
+	}
+	setBool("bot", p.Bot)
+	setBool("hasURL", p.HasURL)
+	setBool("hasAttachments", p.HasAttachments)
+	setBool("hasImage", p.HasImage)
+	setBool("hasVideo", p.HasVideo)
+	setBool("hasAudio", p.HasAudio)
+	if p.Limit != nil {
+		q.Set("limit", strconv.Itoa(*p.Limit))
+	}
+	if p.Offset != nil {
+		q.Set("offset", strconv.Itoa(*p.Offset))
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	return q
+}
+
+// SearchMessages searches messages visible to the owner of token.
+func (c *Client) SearchMessages(ctx context.Context, token string, p *MessageSearchParams) (*MessageSearchResult, error) {
+	if p == nil {
+		p = &MessageSearchParams{}
+	}
+
+	var res MessageSearchResult
+	if err := c.getJSON(ctx, token, "/messages", p.query(), &res); err != nil {
+		return nil, err
+	}
+	if res.Hits == nil {
+		res.Hits = []Message{}
+	}
+	return &res, nil
+}
+
+// PostMessageParams is the body of a message posted to a channel.
+type PostMessageParams struct {
+	Content string `json:"content"`
+	// Embed converts mentions and channel links in the content into traQ's embedded form.
+	Embed bool `json:"embed"`
+}
+
+// PostMessage posts a message to a channel as the owner of token.
+// The token must have been granted the "write" scope; otherwise traQ responds with 403.
+func (c *Client) PostMessage(ctx context.Context, token, channelID string, p PostMessageParams) (*Message, error) {
+	var m Message
+	if err := c.sendJSON(ctx, token, http.MethodPost, "/channels/"+channelID+"/messages", p, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
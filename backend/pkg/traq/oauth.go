@@ -0,0 +1,83 @@
+package traq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthorizeParams are the parameters of the OAuth2 authorization request (PKCE).
+type AuthorizeParams struct {
+	ClientID      string
+	RedirectURI   string
+	State         string
+	CodeChallenge string // S256
+	Scope         string // space separated, e.g. "read write"
+}
+
+// AuthorizeURL returns the URL to redirect the user to for authorization.
+func (c *Client) AuthorizeURL(p AuthorizeParams) string {
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.ClientID)
+	v.Set("redirect_uri", p.RedirectURI)
+	v.Set("state", p.State)
+	v.Set("code_challenge", p.CodeChallenge)
+	v.Set("code_challenge_method", "S256")
+	if p.Scope != "" {
+		v.Set("scope", p.Scope)
+	}
+	return c.endpoint("/oauth2/authorize", v)
+}
+
+// TokenParams are the parameters of the authorization code token request.
+type TokenParams struct {
+	ClientID     string
+	ClientSecret string // optional
+	Code         string
+	CodeVerifier string
+	RedirectURI  string // optional
+}
+
+// Token is an OAuth2 token issued by traQ.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// ExchangeToken exchanges an authorization code for an access token.
+func (c *Client) ExchangeToken(ctx context.Context, p TokenParams) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("client_id", p.ClientID)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+	form.Set("code", p.Code)
+	form.Set("code_verifier", p.CodeVerifier)
+	if p.RedirectURI != "" {
+		form.Set("redirect_uri", p.RedirectURI)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.do(ctx, "", http.MethodPost, "/oauth2/token", nil, strings.NewReader(form.Encode()), header)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	var t Token
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	return &t, nil
+}
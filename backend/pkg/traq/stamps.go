@@ -0,0 +1,45 @@
+package traq
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Stamp is a stamp registered in traQ.
+type Stamp struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatorID string    `json:"creatorId"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	FileID    string    `json:"fileId"`
+	IsUnicode bool      `json:"isUnicode"`
+}
+
+// GetStamps returns all stamps, including Unicode emoji stamps.
+func (c *Client) GetStamps(ctx context.Context, token string) ([]Stamp, error) {
+	var stamps []Stamp
+	if err := c.getJSON(ctx, token, "/stamps", nil, &stamps); err != nil {
+		return nil, err
+	}
+	return stamps, nil
+}
+
+// AddMessageStamp puts a stamp on a message count times (1 if count is 0) as the owner of token.
+// The token must have been granted the "write" scope; otherwise traQ responds with 403.
+func (c *Client) AddMessageStamp(ctx context.Context, token, messageID, stampID string, count int) error {
+	var body interface{}
+	if count > 0 {
+		body = struct {
+			Count int `json:"count"`
+		}{count}
+	}
+	return c.sendJSON(ctx, token, http.MethodPost, "/messages/"+messageID+"/stamps/"+stampID, body, nil)
+}
+
+// RemoveMessageStamp removes the stamp the owner of token put on a message.
+// The token must have been granted the "write" scope; otherwise traQ responds with 403.
+func (c *Client) RemoveMessageStamp(ctx context.Context, token, messageID, stampID string) error {
+	return c.sendJSON(ctx, token, http.MethodDelete, "/messages/"+messageID+"/stamps/"+stampID, nil, nil)
+}
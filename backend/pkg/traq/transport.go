@@ -0,0 +1,223 @@
+package traq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a Transport while traQ is considered down
+// after repeated failures. Requests fail fast until the circuit closes again.
+var ErrCircuitOpen = errors.New("traQ circuit breaker is open")
+
+// TransportConfig configures the retries and the circuit breaker of a Transport.
+type TransportConfig struct {
+	// MaxRetries is the maximum number of retries of an idempotent request.
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry. It doubles on every retry
+	// and a random delay up to it is used (full jitter).
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. A Retry-After longer than this is not waited for.
+	MaxDelay time.Duration
+	// FailureThreshold is the number of consecutive failures (5xx or network errors)
+	// of a host that opens its circuit.
+	FailureThreshold int
+	// OpenDuration is how long an open circuit rejects requests before letting a trial request through.
+	OpenDuration time.Duration
+}
+
+// DefaultTransportConfig returns the default TransportConfig.
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxRetries:       3,
+		BaseDelay:        100 * time.Millisecond,
+		MaxDelay:         5 * time.Second,
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+	}
+}
+
+// Transport is an http.RoundTripper for outbound traQ traffic. It retries idempotent
+// requests on 429/5xx and network errors with jittered exponential backoff, honors
+// Retry-After, and keeps a circuit breaker per host. It is safe for concurrent use.
+type Transport struct {
+	base http.RoundTripper
+	cfg  TransportConfig
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// NewTransport wraps base (http.DefaultTransport if nil) into a Transport.
+func NewTransport(base http.RoundTripper, cfg TransportConfig) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base:     base,
+		cfg:      cfg,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cb := t.circuit(req.URL.Host)
+	retryable := isIdempotent(req.Method) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		if err := cb.allow(t.cfg, time.Now()); err != nil {
+			return nil, fmt.Errorf("%w (%s)", err, req.URL.Host)
+		}
+
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cb.release()
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(r)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// 呼び出し元のキャンセルは traQ の障害として数えない
+			cb.release()
+			return nil, err
+		case err != nil || resp.StatusCode >= 500:
+			cb.failure(t.cfg, time.Now())
+		case resp.StatusCode == http.StatusTooManyRequests:
+			cb.release()
+		default:
+			cb.success()
+			return resp, nil
+		}
+
+		if !retryable || attempt >= t.cfg.MaxRetries {
+			return resp, err
+		}
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if d, ok := retryAfter(resp, time.Now()); ok {
+				if d > t.cfg.MaxDelay {
+					// 待ちきれないので traQ の応答をそのまま返す
+					return resp, nil
+				}
+				delay = d
+			}
+			// コネクションを再利用できるよう本文を読み捨てる
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a random delay up to BaseDelay * 2^attempt (capped at MaxDelay).
+func (t *Transport) backoff(attempt int) time.Duration {
+	d := t.cfg.BaseDelay << attempt
+	if d <= 0 || d > t.cfg.MaxDelay {
+		d = t.cfg.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
+
+func (t *Transport) circuit(host string) *circuit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cb, ok := t.circuits[host]
+	if !ok {
+		cb = &circuit{}
+		t.circuits[host] = cb
+	}
+	return cb
+}
+
+// circuit は1つのホストに対する circuit breaker。
+// 連続した失敗が閾値に達すると一定時間リクエストを拒否し、その後は1件だけ試しに通して成否で閉じるか開き直すかを決める。
+type circuit struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	// 開いた後に試しに通しているリクエストがあるか
+	trial bool
+}
+
+func (cb *circuit) allow(cfg TransportConfig, now time.Time) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cfg.FailureThreshold <= 0 || cb.failures < cfg.FailureThreshold {
+		return nil
+	}
+	if now.Before(cb.openUntil) || cb.trial {
+		return ErrCircuitOpen
+	}
+	cb.trial = true
+	return nil
+}
+
+func (cb *circuit) success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures = 0
+	cb.trial = false
+}
+
+func (cb *circuit) failure(cfg TransportConfig, now time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures++
+	cb.trial = false
+	if cfg.FailureThreshold > 0 && cb.failures >= cfg.FailureThreshold {
+		cb.openUntil = now.Add(cfg.OpenDuration)
+	}
+}
+
+// release は成否を判断できなかったリクエストの試行を終える
+func (cb *circuit) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.trial = false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header (seconds or an HTTP date).
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
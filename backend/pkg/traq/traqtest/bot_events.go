@@ -0,0 +1,124 @@
+package traqtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+)
+
+// BotEventSender delivers traQ bot events to a bot in HTTP mode, as traQ does.
+// Users and stamps in the payloads are filled in from the fixtures of the server.
+type BotEventSender struct {
+	server            *Server
+	url               string
+	verificationToken string
+	client            *http.Client
+}
+
+// NewBotEventSender creates a sender that posts events to url with verificationToken.
+func (s *Server) NewBotEventSender(url, verificationToken string) *BotEventSender {
+	return &BotEventSender{
+		server:            s,
+		url:               url,
+		verificationToken: verificationToken,
+		client:            &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send posts an event with payload and returns the status code of the bot's response.
+func (b *BotEventSender) Send(ctx context.Context, event string, payload interface{}) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set(traq.BotEventHeader, event)
+	req.Header.Set(traq.BotTokenHeader, b.verificationToken)
+	req.Header.Set(traq.BotRequestIDHeader, randomString())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// Ping sends PING.
+func (b *BotEventSender) Ping(ctx context.Context) (int, error) {
+	return b.Send(ctx, traq.BotEventPing, traq.PingEvent{EventTime: time.Now()})
+}
+
+// MessageCreated sends MESSAGE_CREATED for m. BaseURLPlaceholder in the content is replaced
+// with the URL of the server, as in fixture messages.
+func (b *BotEventSender) MessageCreated(ctx context.Context, m traq.Message) (int, error) {
+	b.server.mu.Lock()
+	u := b.server.users[m.UserID]
+	b.server.mu.Unlock()
+
+	text := strings.ReplaceAll(m.Content, BaseURLPlaceholder, b.server.URL)
+	return b.Send(ctx, traq.BotEventMessageCreated, traq.MessageCreatedEvent{
+		EventTime: time.Now(),
+		Message: traq.BotEventMessage{
+			ID: m.ID,
+			User: traq.BotEventUser{
+				ID:          u.ID,
+				Name:        u.Name,
+				DisplayName: u.DisplayName,
+				IconID:      u.IconFileID,
+				Bot:         u.Bot,
+			},
+			ChannelID: m.ChannelID,
+			Text:      text,
+			PlainText: text,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+	})
+}
+
+// BotMessageStampsUpdated sends BOT_MESSAGE_STAMPS_UPDATED with all stamps on a message.
+func (b *BotEventSender) BotMessageStampsUpdated(ctx context.Context, messageID string, stamps []traq.MessageStamp) (int, error) {
+	b.server.mu.Lock()
+	names := make(map[string]string, len(b.server.stamps))
+	for _, s := range b.server.stamps {
+		names[s.ID] = s.Name
+	}
+	b.server.mu.Unlock()
+
+	ev := traq.BotMessageStampsUpdatedEvent{
+		EventTime: time.Now(),
+		MessageID: messageID,
+		Stamps:    make([]traq.BotMessageStamp, 0, len(stamps)),
+	}
+	for _, s := range stamps {
+		ev.Stamps = append(ev.Stamps, traq.BotMessageStamp{
+			StampID:   s.StampID,
+			UserID:    s.UserID,
+			StampName: names[s.StampID],
+			Count:     s.Count,
+			CreatedAt: s.CreatedAt,
+			UpdatedAt: s.UpdatedAt,
+		})
+	}
+	return b.Send(ctx, traq.BotEventBotMessageStampsUpdated, ev)
+}
+
+// MessageDeleted sends MESSAGE_DELETED.
+func (b *BotEventSender) MessageDeleted(ctx context.Context, messageID, channelID string) (int, error) {
+	ev := traq.MessageDeletedEvent{EventTime: time.Now()}
+	ev.Message.ID = messageID
+	ev.Message.ChannelID = channelID
+	return b.Send(ctx, traq.BotEventMessageDeleted, ev)
+}
@@ -0,0 +1,47 @@
+// Package traqtest は結合テスト用の traQ のフェイク実装を提供します。
+// net/http/httptest 上で動作し、アプリが利用する traQ API をフィクスチャのデータで再現します。
+package traqtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+)
+
+// Fixtures is the initial data of a fake traQ server.
+type Fixtures struct {
+	Users    []traq.UserDetail `json:"users"`
+	Channels []traq.Channel    `json:"channels"`
+	Messages []traq.Message    `json:"messages"`
+	Files    []File            `json:"files"`
+	Stamps   []traq.Stamp      `json:"stamps"`
+	// Tokens maps access tokens to the IDs of the users they belong to.
+	Tokens map[string]string `json:"tokens"`
+	// OAuthUser is the ID of the user who approves OAuth authorization requests,
+	// i.e. the user logged in to traQ in the browser.
+	OAuthUser string `json:"oauthUser"`
+}
+
+// File is a file uploaded to the fake traQ.
+// Content and Thumbnail are base64 encoded in JSON fixtures.
+type File struct {
+	traq.FileInfo
+	Content       []byte `json:"content"`
+	Thumbnail     []byte `json:"thumbnail"`
+	ThumbnailMime string `json:"thumbnailMime"`
+}
+
+// LoadFixtures reads fixtures from a JSON file.
+func LoadFixtures(path string) (*Fixtures, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fx Fixtures
+	if err := json.Unmarshal(b, &fx); err != nil {
+		return nil, fmt.Errorf("failed to decode fixtures (%s): %w", path, err)
+	}
+	return &fx, nil
+}
@@ -0,0 +1,737 @@
+package traqtest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/traP-jp/1m25_10/backend/pkg/traq"
+
+	"github.com/google/uuid"
+)
+
+// BaseURLPlaceholder in the content of fixture messages is replaced with the URL of the server,
+// so that fixtures can embed file and message URLs (e.g. "{{baseURL}}/files/<id>").
+const BaseURLPlaceholder = "{{baseURL}}"
+
+// Server is a fake traQ server. All handlers share the fixture data, which
+// may be modified through the Server's methods while it is running.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	users    map[string]traq.UserDetail
+	channels []traq.Channel
+	messages []traq.Message
+	files    map[string]File
+	stamps   []traq.Stamp
+	tokens   map[string]string
+	// 認可で付与されたスコープ（スペース区切り）。無いトークンは全てのスコープを持つ
+	scopes map[string]string
+	codes  map[string]authCode
+
+	userRequests map[string]int
+	requests     map[string]int
+	faults       []*Fault
+	inFlight     int
+	peakInFlight int
+
+	oauthUser string
+}
+
+type authCode struct {
+	userID        string
+	codeChallenge string
+	scope         string
+}
+
+// NewServer starts a fake traQ server seeded with fx. The caller should call Close when finished.
+func NewServer(fx *Fixtures) *Server {
+	s := &Server{
+		users:  make(map[string]traq.UserDetail),
+		files:  make(map[string]File),
+		tokens: make(map[string]string),
+		scopes: make(map[string]string),
+		codes:  make(map[string]authCode),
+
+		userRequests: make(map[string]int),
+		requests:     make(map[string]int),
+		oauthUser:    fx.OAuthUser,
+	}
+	for _, u := range fx.Users {
+		s.users[u.ID] = u
+	}
+	s.channels = append(s.channels, fx.Channels...)
+	for _, f := range fx.Files {
+		s.files[f.ID] = f
+	}
+	s.stamps = append(s.stamps, fx.Stamps...)
+	for token, userID := range fx.Tokens {
+		s.tokens[token] = userID
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/oauth2/authorize", s.handleAuthorize)
+	mux.HandleFunc("POST /api/v3/oauth2/token", s.handleToken)
+	mux.HandleFunc("GET /api/v3/users", s.authenticated(s.handleGetUsers))
+	mux.HandleFunc("GET /api/v3/users/me", s.authenticated(s.handleGetMe))
+	mux.HandleFunc("GET /api/v3/users/{id}", s.authenticated(s.handleGetUser))
+	mux.HandleFunc("GET /api/v3/channels", s.authenticated(s.handleGetChannels))
+	mux.HandleFunc("GET /api/v3/channels/{id}", s.authenticated(s.handleGetChannel))
+	mux.HandleFunc("POST /api/v3/channels/{id}/messages", s.authenticated(s.requireScope("write", s.handlePostMessage)))
+	mux.HandleFunc("GET /api/v3/messages", s.authenticated(s.handleSearchMessages))
+	mux.HandleFunc("POST /api/v3/messages/{id}/stamps/{stampId}", s.authenticated(s.requireScope("write", s.handleAddMessageStamp)))
+	mux.HandleFunc("DELETE /api/v3/messages/{id}/stamps/{stampId}", s.authenticated(s.requireScope("write", s.handleRemoveMessageStamp)))
+	mux.HandleFunc("GET /api/v3/files/{id}", s.authenticated(s.handleGetFile))
+	mux.HandleFunc("GET /api/v3/files/{id}/thumbnail", s.authenticated(s.handleGetThumbnail))
+	mux.HandleFunc("GET /api/v3/files/{id}/meta", s.authenticated(s.handleGetFileMeta))
+	mux.HandleFunc("GET /api/v3/stamps", s.authenticated(s.handleGetStamps))
+	s.Server = httptest.NewServer(s.injectFaults(mux))
+
+	// URLが確定してからメッセージ本文のプレースホルダーを置き換える
+	for _, m := range fx.Messages {
+		m.Content = strings.ReplaceAll(m.Content, BaseURLPlaceholder, s.URL)
+		s.messages = append(s.messages, m)
+	}
+
+	return s
+}
+
+// AddMessage posts a message to the fake traQ.
+func (s *Server) AddMessage(m traq.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, m)
+}
+
+// Message returns a message of the fake traQ, including the ones posted through the API.
+func (s *Server) Message(id string) (traq.Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.messages {
+		if m.ID == id {
+			m.Stamps = slices.Clone(m.Stamps)
+			return m, true
+		}
+	}
+	return traq.Message{}, false
+}
+
+// DeleteMessage removes a message from the fake traQ. It reports whether the message existed.
+func (s *Server) DeleteMessage(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, m := range s.messages {
+		if m.ID == id {
+			s.messages = append(s.messages[:i], s.messages[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// FileURL returns the URL under which a file is embedded in message contents.
+func (s *Server) FileURL(fileID string) string {
+	return s.URL + "/files/" + fileID
+}
+
+// ========== fault injection ==========
+
+// Fault makes the fake traQ fail or delay the requests whose path starts with PathPrefix.
+type Fault struct {
+	PathPrefix string
+	// Status is the status code of the failed responses. If it is 0, the requests are handled normally after Delay.
+	Status int
+	// Delay is waited before responding.
+	Delay time.Duration
+	// RetryAfter is sent as the Retry-After header if not empty.
+	RetryAfter string
+	// Count is the number of requests to fail. If it is negative, requests fail until ClearFaults is called.
+	Count int
+}
+
+// InjectFault adds a fault. Faults are applied in the order they were added.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests returns how many requests were made to path, including failed ones.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// PeakConcurrentRequests returns the maximum number of requests handled at the same time
+// since the previous call.
+func (s *Server) PeakConcurrentRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	peak := s.peakInFlight
+	s.peakInFlight = s.inFlight
+	return peak
+}
+
+func (s *Server) injectFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		s.inFlight++
+		s.peakInFlight = max(s.peakInFlight, s.inFlight)
+		var fault *Fault
+		for _, f := range s.faults {
+			if f.Count != 0 && strings.HasPrefix(r.URL.Path, f.PathPrefix) {
+				if f.Count > 0 {
+					f.Count--
+				}
+				fault = f
+				break
+			}
+		}
+		var status int
+		var retryAfter string
+		var delay time.Duration
+		if fault != nil {
+			status, retryAfter, delay = fault.Status, fault.RetryAfter, fault.Delay
+		}
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			s.inFlight--
+			s.mu.Unlock()
+		}()
+
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		if status == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		writeError(w, status, http.StatusText(status))
+	})
+}
+
+// ========== auth ==========
+
+type ctxUserHandler func(w http.ResponseWriter, r *http.Request, userID string)
+
+// authenticated rejects requests without a known Bearer token.
+func (s *Server) authenticated(next ctxUserHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		s.mu.Lock()
+		userID, known := s.tokens[token]
+		s.mu.Unlock()
+		if !ok || !known {
+			writeError(w, http.StatusUnauthorized, "You are not logged in")
+			return
+		}
+		next(w, r, userID)
+	}
+}
+
+// AddToken registers an access token of a user that was granted scope (space separated, e.g. "read").
+func (s *Server) AddToken(token, userID, scope string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = userID
+	s.scopes[token] = scope
+}
+
+// requireScope rejects requests whose token was not granted scope with 403, like traQ does.
+func (s *Server) requireScope(scope string, next ctxUserHandler) ctxUserHandler {
+	return func(w http.ResponseWriter, r *http.Request, userID string) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		s.mu.Lock()
+		granted, limited := s.scopes[token]
+		s.mu.Unlock()
+		if limited && !contains(strings.Fields(granted), scope) {
+			writeError(w, http.StatusForbidden, "you are not permitted to do this")
+			return
+		}
+		next(w, r, userID)
+	}
+}
+
+// handleAuthorize approves every request as OAuthUser and redirects back with a code.
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI := q.Get("redirect_uri")
+	if q.Get("response_type") != "code" || redirectURI == "" || q.Get("code_challenge_method") != "S256" {
+		writeError(w, http.StatusBadRequest, "invalid authorization request")
+		return
+	}
+
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = authCode{
+		userID:        s.oauthUser,
+		codeChallenge: q.Get("code_challenge"),
+		scope:         q.Get("scope"),
+	}
+	s.mu.Unlock()
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid redirect_uri")
+		return
+	}
+	v := u.Query()
+	v.Set("code", code)
+	v.Set("state", q.Get("state"))
+	u.RawQuery = v.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeError(w, http.StatusBadRequest, "invalid token request")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.codes[r.PostForm.Get("code")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid code")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != code.codeChallenge {
+		writeError(w, http.StatusBadRequest, "invalid code_verifier")
+		return
+	}
+	delete(s.codes, r.PostForm.Get("code"))
+
+	token := randomString()
+	s.tokens[token] = code.userID
+	s.scopes[token] = code.scope
+	writeJSON(w, http.StatusOK, traq.Token{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   3600,
+		Scope:       code.scope,
+	})
+}
+
+// ========== users / channels ==========
+
+func (s *Server) handleGetMe(w http.ResponseWriter, _ *http.Request, userID string) {
+	s.mu.Lock()
+	u, ok := s.users[userID]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// handleGetUsers returns the users matching the name query (or all users).
+func (s *Server) handleGetUsers(w http.ResponseWriter, r *http.Request, _ string) {
+	name := r.URL.Query().Get("name")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := make([]traq.User, 0)
+	for _, u := range s.users {
+		if name != "" && u.Name != name {
+			continue
+		}
+		users = append(users, traq.User{
+			ID:          u.ID,
+			Name:        u.Name,
+			DisplayName: u.DisplayName,
+			IconFileID:  u.IconFileID,
+			Bot:         u.Bot,
+			State:       u.State,
+			UpdatedAt:   u.UpdatedAt,
+		})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	writeJSON(w, http.StatusOK, users)
+}
+
+// UserRequests returns how many times GET /users/{id} was requested for id.
+func (s *Server) UserRequests(id string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.userRequests[id]
+}
+
+func (s *Server) handleGetUser(w http.ResponseWriter, r *http.Request, _ string) {
+	s.mu.Lock()
+	s.userRequests[r.PathValue("id")]++
+	u, ok := s.users[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (s *Server) handleGetChannels(w http.ResponseWriter, _ *http.Request, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, traq.ChannelList{Public: s.channels})
+}
+
+func (s *Server) handleGetChannel(w http.ResponseWriter, r *http.Request, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.channels {
+		if ch.ID == r.PathValue("id") {
+			writeJSON(w, http.StatusOK, ch)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "not found")
+}
+
+func (s *Server) handleGetStamps(w http.ResponseWriter, _ *http.Request, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.stamps)
+}
+
+// ========== messages ==========
+
+// handleSearchMessages implements the filters of GET /messages on the fixture messages.
+// word is matched as a plain substring of the content.
+func (s *Server) handleSearchMessages(w http.ResponseWriter, r *http.Request, _ string) {
+	q := r.URL.Query()
+
+	limit, offset := 20, 0
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid offset")
+			return
+		}
+		offset = n
+	}
+
+	var after, before time.Time
+	for key, dst := range map[string]*time.Time{"after": &after, "before": &before} {
+		if v := q.Get(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+key)
+				return
+			}
+			*dst = t
+		}
+	}
+
+	boolParam := func(key string) *bool {
+		v := q.Get(key)
+		if v == "" {
+			return nil
+		}
+		b := v == "true"
+		return &b
+	}
+	bot := boolParam("bot")
+	hasURL := boolParam("hasURL")
+	hasAttachments := boolParam("hasAttachments")
+	hasImage := boolParam("hasImage")
+	hasVideo := boolParam("hasVideo")
+	hasAudio := boolParam("hasAudio")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matchBool := func(want *bool, got bool) bool {
+		return want == nil || *want == got
+	}
+	hits := make([]traq.Message, 0)
+	for _, m := range s.messages {
+		if w := q.Get("word"); w != "" && !strings.Contains(m.Content, w) {
+			continue
+		}
+		if !after.IsZero() && !m.CreatedAt.After(after) {
+			continue
+		}
+		if !before.IsZero() && !m.CreatedAt.Before(before) {
+			continue
+		}
+		if in := q.Get("in"); in != "" && m.ChannelID != in {
+			continue
+		}
+		if from := q["from"]; len(from) > 0 && !contains(from, m.UserID) {
+			continue
+		}
+		if to := q["to"]; len(to) > 0 && !mentionsAny(m.Content, to) {
+			continue
+		}
+		if cit := q.Get("citation"); cit != "" && !strings.Contains(m.Content, s.URL+"/messages/"+cit) {
+			continue
+		}
+		if !matchBool(bot, s.users[m.UserID].Bot) {
+			continue
+		}
+		if !matchBool(hasURL, strings.Contains(m.Content, "http://") || strings.Contains(m.Content, "https://")) {
+			continue
+		}
+		mimes := s.attachedMimes(m.Content)
+		if !matchBool(hasAttachments, len(mimes) > 0) ||
+			!matchBool(hasImage, anyPrefix(mimes, "image/")) ||
+			!matchBool(hasVideo, anyPrefix(mimes, "video/")) ||
+			!matchBool(hasAudio, anyPrefix(mimes, "audio/")) {
+			continue
+		}
+		hits = append(hits, m)
+	}
+
+	sortMessages(hits, q.Get("sort"))
+
+	total := len(hits)
+	if offset > len(hits) {
+		offset = len(hits)
+	}
+	hits = hits[offset:min(offset+limit, len(hits))]
+
+	writeJSON(w, http.StatusOK, traq.MessageSearchResult{TotalHits: total, Hits: hits})
+}
+
+// handlePostMessage posts a message to a channel. Embedding (embed=true) is not emulated.
+func (s *Server) handlePostMessage(w http.ResponseWriter, r *http.Request, userID string) {
+	var req traq.PostMessageParams
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, "invalid message")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	channelID := r.PathValue("id")
+	if !slices.ContainsFunc(s.channels, func(ch traq.Channel) bool { return ch.ID == channelID }) {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	now := time.Now().UTC()
+	m := traq.Message{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		ChannelID: channelID,
+		Content:   req.Content,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Stamps:    []traq.MessageStamp{},
+	}
+	s.messages = append(s.messages, m)
+	writeJSON(w, http.StatusCreated, m)
+}
+
+// handleAddMessageStamp puts a stamp on a message (count times, 1 by default; up to 100 per user).
+func (s *Server) handleAddMessageStamp(w http.ResponseWriter, r *http.Request, userID string) {
+	req := struct {
+		Count int `json:"count"`
+	}{Count: 1}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Count < 1 || req.Count > 100 {
+			writeError(w, http.StatusBadRequest, "invalid count")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, stampID, ok := s.messageStamp(w, r)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	i := slices.IndexFunc(m.Stamps, func(st traq.MessageStamp) bool { return st.UserID == userID && st.StampID == stampID })
+	if i < 0 {
+		m.Stamps = append(m.Stamps, traq.MessageStamp{UserID: userID, StampID: stampID, CreatedAt: now})
+		i = len(m.Stamps) - 1
+	}
+	m.Stamps[i].Count = min(m.Stamps[i].Count+req.Count, 100)
+	m.Stamps[i].UpdatedAt = now
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRemoveMessageStamp removes the stamp the user put on a message. It succeeds even if there was none.
+func (s *Server) handleRemoveMessageStamp(w http.ResponseWriter, r *http.Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, stampID, ok := s.messageStamp(w, r)
+	if !ok {
+		return
+	}
+	m.Stamps = slices.DeleteFunc(m.Stamps, func(st traq.MessageStamp) bool { return st.UserID == userID && st.StampID == stampID })
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// messageStamp returns the message and the stamp ID in the path, or writes an error response.
+// s.mu must be held.
+func (s *Server) messageStamp(w http.ResponseWriter, r *http.Request) (*traq.Message, string, bool) {
+	i := slices.IndexFunc(s.messages, func(m traq.Message) bool { return m.ID == r.PathValue("id") })
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return nil, "", false
+	}
+	stampID := r.PathValue("stampId")
+	if !slices.ContainsFunc(s.stamps, func(st traq.Stamp) bool { return st.ID == stampID }) {
+		writeError(w, http.StatusBadRequest, "this stamp doesn't exist")
+		return nil, "", false
+	}
+	// フィクスチャや返したメッセージとスタンプの配列を共有しないよう、書き換える前に複製する
+	m := &s.messages[i]
+	m.Stamps = slices.Clone(m.Stamps)
+	return m, stampID, true
+}
+
+// attachedMimes returns the mime types of the fixture files embedded in content.
+func (s *Server) attachedMimes(content string) []string {
+	mimes := make([]string, 0)
+	for id, f := range s.files {
+		if strings.Contains(content, "/files/"+id) {
+			mimes = append(mimes, f.Mime)
+		}
+	}
+	return mimes
+}
+
+func sortMessages(msgs []traq.Message, key string) {
+	var less func(a, b traq.Message) bool
+	switch key {
+	case "createdAt":
+		less = func(a, b traq.Message) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	case "updatedAt":
+		less = func(a, b traq.Message) bool { return a.UpdatedAt.Before(b.UpdatedAt) }
+	case "-updatedAt":
+		less = func(a, b traq.Message) bool { return a.UpdatedAt.After(b.UpdatedAt) }
+	default: // -createdAt
+		less = func(a, b traq.Message) bool { return a.CreatedAt.After(b.CreatedAt) }
+	}
+	sort.SliceStable(msgs, func(i, j int) bool { return less(msgs[i], msgs[j]) })
+}
+
+// mentionsAny reports whether content has a user mention embedding (!{"type":"user",...,"id":"..."}) of any of ids.
+func mentionsAny(content string, ids []string) bool {
+	for _, id := range ids {
+		if strings.Contains(content, `"id":"`+id+`"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// ========== files ==========
+
+func (s *Server) handleGetFile(w http.ResponseWriter, r *http.Request, _ string) {
+	s.mu.Lock()
+	f, ok := s.files[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	serveContent(w, r, f.Mime, f.MD5, f.CreatedAt, f.Content)
+}
+
+func (s *Server) handleGetThumbnail(w http.ResponseWriter, r *http.Request, _ string) {
+	s.mu.Lock()
+	f, ok := s.files[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok || len(f.Thumbnail) == 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	sum := sha256.Sum256(f.Thumbnail)
+	serveContent(w, r, f.ThumbnailMime, hex.EncodeToString(sum[:8]), f.CreatedAt, f.Thumbnail)
+}
+
+func (s *Server) handleGetFileMeta(w http.ResponseWriter, r *http.Request, _ string) {
+	s.mu.Lock()
+	f, ok := s.files[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, f.FileInfo)
+}
+
+// serveContent serves body with ETag/Last-Modified so that conditional and range requests work like traQ.
+func serveContent(w http.ResponseWriter, r *http.Request, mime, etag string, modTime time.Time, body []byte) {
+	w.Header().Set("Content-Type", mime)
+	w.Header().Set("Cache-Control", "private, max-age=31536000")
+	if etag != "" {
+		w.Header().Set("ETag", `"`+etag+`"`)
+	}
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(body))
+}
+
+// ========== helpers ==========
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func anyPrefix(list []string, prefix string) bool {
+	for _, s := range list {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
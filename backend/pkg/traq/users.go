@@ -0,0 +1,83 @@
+package traq
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// Me is the authenticated user (the subset of /users/me the app uses).
+type Me struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// UserDetail is the detail of a traQ user.
+type UserDetail struct {
+	ID          string     `json:"id"`
+	State       int        `json:"state"`
+	Bot         bool       `json:"bot"`
+	IconFileID  string     `json:"iconFileId"`
+	DisplayName string     `json:"displayName"`
+	Name        string     `json:"name"`
+	TwitterID   string     `json:"twitterId"`
+	LastOnline  *time.Time `json:"lastOnline"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	Tags        []UserTag  `json:"tags"`
+	Groups      []string   `json:"groups"`
+	Bio         string     `json:"bio"`
+	HomeChannel *string    `json:"homeChannel"`
+}
+
+// UserTag is a tag attached to a user.
+type UserTag struct {
+	TagID     string    `json:"tagId"`
+	Tag       string    `json:"tag"`
+	IsLocked  bool      `json:"isLocked"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// User is a user in the user list.
+type User struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+	IconFileID  string    `json:"iconFileId"`
+	Bot         bool      `json:"bot"`
+	State       int       `json:"state"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// GetMe returns the owner of token.
+func (c *Client) GetMe(ctx context.Context, token string) (*Me, error) {
+	var me Me
+	if err := c.getJSON(ctx, token, "/users/me", nil, &me); err != nil {
+		return nil, err
+	}
+	return &me, nil
+}
+
+// GetUser returns the detail of a user.
+func (c *Client) GetUser(ctx context.Context, token, userID string) (*UserDetail, error) {
+	var u UserDetail
+	if err := c.getJSON(ctx, token, "/users/"+userID, nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUsersByName returns the users whose name is exactly name
+// (at most one, since names are unique), including suspended users.
+func (c *Client) GetUsersByName(ctx context.Context, token, name string) ([]User, error) {
+	q := url.Values{}
+	q.Set("name", name)
+	q.Set("include-suspended", "true")
+
+	var users []User
+	if err := c.getJSON(ctx, token, "/users", q, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
@@ -5,6 +5,8 @@ import type {
   CreateAlbumRequest,
   UpdateAlbumRequest,
   GetAlbumsParams,
+  ShareAlbumRequest,
+  ShareAlbumResponse,
 } from '@/types'
 
 export class AlbumService {
@@ -58,6 +60,12 @@ export class AlbumService {
     return apiClient.delete<void>(`/albums/${albumId}`)
   }
 
+  // アルバムをtraQのチャンネルに共有（POST /albums/{id}/share/traq）
+  // write スコープを許可していない場合は 403 (error: write_scope_required) になる
+  async shareAlbumToTraq(albumId: string, request: ShareAlbumRequest): Promise<ShareAlbumResponse> {
+    return apiClient.post<ShareAlbumResponse>(`/albums/${albumId}/share/traq`, request)
+  }
+
   // 特定のユーザーのアルバム取得（convenience method）
   async getAlbumsByCreator(creatorId: string): Promise<AlbumItem[]> {
     return this.getAlbums({ creator_id: creatorId })
@@ -38,6 +38,8 @@ class ApiClient {
           const apiError: ApiError = {
             error: error.response.data?.error || `HTTP ${error.response.status}`,
             message: error.response.data?.message || error.message,
+            details: error.response.data?.details,
+            request_id: error.response.data?.request_id,
           }
           return Promise.reject(apiError)
         } else if (error.request) {
@@ -1,5 +1,12 @@
 import { apiClient } from './apiClient'
-import type { Image, ImageDetail, GetImagesParams, GetImagesResponse } from '@/types'
+import type {
+  Image,
+  ImageDetail,
+  GetImagesParams,
+  GetImagesResponse,
+  GetImageHistogramParams,
+  ImageHistogram,
+} from '@/types'
 import { getAlbumChanceStampId } from '@/config/env'
 
 export class ImageService {
@@ -47,6 +54,22 @@ export class ImageService {
   async getImageDetail(imageId: string): Promise<ImageDetail> {
     return apiClient.get<ImageDetail>(`/images/${imageId}`)
   }
+
+  // 日ごと・週ごとの画像の投稿数（GET /images/histogram）
+  async getHistogram(params?: GetImageHistogramParams): Promise<ImageHistogram> {
+    return apiClient.get<ImageHistogram>('/images/histogram', { ...params })
+  }
+
+  // 画像を最初に投稿したtraQのメッセージにスタンプを付ける（POST /images/{id}/stamps/{stampId}）
+  // write スコープを許可していない場合は 403 (error: write_scope_required) になる
+  async addStamp(imageId: string, stampId: string): Promise<void> {
+    return apiClient.post<void>(`/images/${imageId}/stamps/${stampId}`)
+  }
+
+  // 付けたスタンプを外す（DELETE /images/{id}/stamps/{stampId}）
+  async removeStamp(imageId: string, stampId: string): Promise<void> {
+    return apiClient.delete<void>(`/images/${imageId}/stamps/${stampId}`)
+  }
 }
 
 export const imageService = new ImageService()
@@ -31,6 +31,19 @@ export interface UpdateAlbumRequest {
   images?: string[] // 画像UUIDの配列
 }
 
+// アルバムのtraQへの共有（POST /albums/{id}/share/traq）
+export interface ShareAlbumRequest {
+  channel_id: string // UUID
+  comment?: string
+  max_images?: number // 0-10, default: 10
+}
+
+export interface ShareAlbumResponse {
+  message_id: string // UUID
+  channel_id: string // UUID
+  url: string // 投稿したメッセージのURL
+}
+
 // クエリパラメータ
 export interface GetAlbumsParams {
   creator_id?: string // UUID
@@ -2,8 +2,11 @@
 
 // エラーレスポンス
 export interface ApiError {
+  // エラーコード（例: "not_found", "traq_unavailable"）
   error: string
   message?: string
+  details?: unknown
+  request_id?: string
 }
 
 // 共通のstate型
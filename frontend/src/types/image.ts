@@ -28,3 +28,27 @@ export interface GetImagesParams {
   offset?: number // オフセット (デフォルト: 0)
   stampId?: string // スタンプフィルタ（アルバムチャンス用）
 }
+
+// 画像の投稿数の集計（GET /images/histogram）
+export interface GetImageHistogramParams {
+  in?: string // チャンネルUUID
+  channel?: string // チャンネルのパス（例: gps/times）
+  from?: string // 投稿者のユーザーUUID
+  bucket?: 'day' | 'week' // デフォルト: day（週は月曜始まり）
+  tz?: string // IANAタイムゾーン（デフォルト: Asia/Tokyo）
+  after?: string // RFC3339 または YYYY-MM-DD
+  before?: string // RFC3339 または YYYY-MM-DD（含まない）
+}
+
+export interface ImageHistogramBucket {
+  start: string // ISO 8601 format
+  end: string // ISO 8601 format
+  count: number
+}
+
+export interface ImageHistogram {
+  bucket: 'day' | 'week'
+  timezone: string
+  total: number
+  buckets: ImageHistogramBucket[]
+}